@@ -3,10 +3,14 @@
 package bot
 
 import (
+    "context"
+    "errors"
     "fmt"
     "log"
+    "path/filepath"
     "strings"
     "sync"
+    "time"
 
     "github.com/gregthemadmonk/mctg-server-bot/tg_api"
 )
@@ -14,27 +18,121 @@ import (
 // Bot config
 type Config struct {
     // Telegram bot API token
-    ApiToken      string `json:"api_token"`
+    ApiToken      string `json:"api_token" reload:"restart"`
+    // File to read the API token from, overrides `api_token`
+    ApiTokenFile  string `json:"api_token_file,omitempty" reload:"restart"`
+    // Telegram bot API server URL. Defaults to the official server, set to
+    // use a self-hosted telegram-bot-api server
+    ApiUrl        string `json:"api_url,omitempty" reload:"restart"`
+    // Proxy to reach the API through: http://, https://, socks5:// or
+    // socks5h:// URL
+    Proxy         string `json:"proxy,omitempty" reload:"restart"`
     // Telegram channel ID for the bot to live in
     ChatId        int    `json:"chat_id"`
+    // Chat for the alerts meant for the admins (server hangs, errors...).
+    // If not set, they go to the main chat with a mention of the admin
+    AdminChatId   int    `json:"admin_chat_id,omitempty"`
     // Username of a Telegram user who can issue slash-commands directly to
     // the server
     AdminUsername string `json:"admin_username,omitempty"`
+    // File to spool the messages that could not be delivered to Telegram
+    // into. If empty, undelivered messages are only kept in RAM
+    OutboxPath    string `json:"outbox_path,omitempty" reload:"restart"`
+    // Collapse the messages accumulated while Telegram was unreachable into
+    // a single summary message
+    OutboxSummarize bool `json:"outbox_summarize,omitempty"`
+    // Make the placeholders of photos, stickers and other media relayed to
+    // Minecraft clickable links to the message in Telegram
+    MediaLinks    bool   `json:"media_links,omitempty"`
+    // Parse mode for the bot's messages: "MarkdownV2", "HTML" or empty for
+    // plain text without formatting
+    ParseMode     string `json:"parse_mode,omitempty"`
 } // <-- struct Config
 
+// Check the config values
+func (self *Config) Validate() []error {
+    var errs []error
+    if len(self.ApiToken) == 0 {
+        errs = append(
+            errs, errors.New("api_token: is required (or api_token_file)"),
+        )
+    }
+    if self.ChatId == 0 {
+        errs = append(errs, errors.New("chat_id: is required"))
+    }
+    opts := tg_api.ClientOptions{ BaseUrl: self.ApiUrl }
+    if _, err := tg_api.MakeClient(self.ApiToken, opts); err != nil {
+        errs = append(errs, fmt.Errorf("api_url: %w", err))
+    }
+    opts = tg_api.ClientOptions{ Proxy: self.Proxy }
+    if _, err := tg_api.MakeClient(self.ApiToken, opts); err != nil {
+        errs = append(errs, fmt.Errorf("proxy: %w", err))
+    }
+    switch self.ParseMode {
+    case "", tg_api.PM_MARKDOWN, tg_api.PM_HTML:
+    default:
+        errs = append(
+            errs,
+            fmt.Errorf(
+                "parse_mode: must be %q, %q or empty, got %q",
+                tg_api.PM_MARKDOWN, tg_api.PM_HTML, self.ParseMode,
+            ),
+        )
+    }
+    return errs
+} // <-- Config::Validate()
+
 const (
     BS_RUNNING  = iota
     BS_STOPPING = iota
     BS_STOPPED  = iota
 )
 
+// Long polling timeout for getUpdates, in seconds
+const POLL_TIMEOUT = 30
+
+// Delay before retrying a failed getUpdates request
+const RETRY_DELAY = 5 * time.Second
+
+// How long stopping the bot waits for the outbox to be flushed
+const STOP_FLUSH_TIMEOUT = 10 * time.Second
+
+// Maximum length of a document caption, in UTF-16 code units
+const CAPTION_LIMIT = 1024
+
+// Maximum length of a message, in UTF-16 code units. Longer messages are
+// split
+const MESSAGE_LIMIT = 4096
+
 // The bot state
 type bot struct {
     config  Config
+    // Guards `config`, which can be replaced by Reload()
+    config_mu sync.RWMutex
+    client  *tg_api.Client
     running uint
+    // Cancelled when the bot stops, interrupts pending API requests
+    ctx     context.Context
+    cancel  context.CancelFunc
     wg      sync.WaitGroup
     out     chan any
     in      chan any
+
+    // Messages waiting for Telegram to become reachable
+    outbox       *outbox
+    // Signals the input handler that the outbox may be flushed
+    outbox_ready chan struct{}
+    // Closed when the input handler exits
+    inputs_done  chan struct{}
+
+    // Minecraft players whose chat messages the bot has relayed, by the
+    // Telegram message ID. Replies to these are whispered to the player
+    senders *history[string]
+    // Telegram messages relayed to Minecraft, to show what the edited ones
+    // said before
+    relayed *history[relayed_message]
+    // IDs of the users seen in the chat, for mentions
+    users   *user_ids
 } // <-- struct bot
 
 // Create bot from the config
@@ -44,21 +142,40 @@ func MakeBot(
     ret := bot{
         config:  bot_cfg,
         running: BS_STOPPED,
+        ctx:     context.Background(),
         out:     make(chan any),
         in:      make(chan any),
+
+        outbox_ready: make(chan struct{}, 1),
+        senders:      make_history[string](HISTORY_SIZE),
+        relayed:      make_history[relayed_message](HISTORY_SIZE),
+        users:        make_user_ids(),
+    }
+
+    client, client_err := tg_api.MakeClient(
+        bot_cfg.ApiToken,
+        tg_api.ClientOptions{
+            BaseUrl: bot_cfg.ApiUrl,
+            Proxy:   bot_cfg.Proxy,
+        },
+    )
+    if client_err != nil {
+        return nil, client_err
+    }
+    ret.client = client
+
+    if ob, err := make_outbox(bot_cfg.OutboxPath); err == nil {
+        ret.outbox = ob
+    } else {
+        return nil, err
+    }
+    if n := ret.outbox.Len(); n != 0 {
+        log.Println(n, "undelivered messages left in the outbox")
     }
 
     log.Println("Checking Telegram bot API accessibility...")
-    url := ret.Uri("getMe")
-    if res, err := tg_api.ExchangeInto[tg_api.GetMe](url); err == nil {
-        if !res.Ok {
-            return nil, res
-        }
-        log.Printf(
-            "Running as @%s (%s)\n",
-            res.Result.Username,
-            res.Result.FirstName,
-        )
+    if me, err := ret.client.GetMe(ret.ctx); err == nil {
+        log.Printf("Running as @%s (%s)\n", me.Username, me.FirstName)
     } else {
         return nil, err
     }
@@ -66,6 +183,21 @@ func MakeBot(
     return &ret, nil
 } // <-- MakeBot(cfg)
 
+// Get the current config
+func (self *bot) cfg() Config {
+    self.config_mu.RLock()
+    defer self.config_mu.RUnlock()
+    return self.config
+} // <-- bot::cfg()
+
+// Replace the config. Fields marked with `reload:"restart"` only take effect
+// after the bot is restarted
+func (self *bot) Reload(bot_cfg Config) {
+    self.config_mu.Lock()
+    defer self.config_mu.Unlock()
+    self.config = bot_cfg
+} // <-- bot::Reload(bot_cfg)
+
 // Get bot's output event channel
 func (self *bot) Out() <-chan any {
     return self.out
@@ -76,82 +208,343 @@ func (self *bot) In() chan<- any {
     return self.in
 } // <-- bot::In()
 
-func (self *bot) Uri(endpoint string) string {
-    return fmt.Sprintf(
-        "%s%s/%s", tg_api.API_BASE, self.config.ApiToken, endpoint,
+// Chat to send the message to, and the message adjusted for it
+func (self *bot) route(message Text, admin bool) (int, Text) {
+    config := self.cfg()
+    if !admin {
+        return config.ChatId, message
+    }
+    if config.AdminChatId != 0 {
+        return config.AdminChatId, message
+    }
+    if len(config.AdminUsername) != 0 {
+        // Make sure the admin notices it in the main chat
+        mention := Text{ Plain("@" + config.AdminUsername + " ") }
+        return config.ChatId, append(mention, message...)
+    }
+    return config.ChatId, message
+} // <-- bot::route(message, admin)
+
+// Check if Telegram rejected the message because of its markup
+func is_markup_error(err error) bool {
+    var api_err *tg_api.Error
+    return errors.As(err, &api_err) && api_err.ErrorCode == 400 &&
+        strings.Contains(api_err.Description, "can't parse entities")
+} // <-- is_markup_error(err)
+
+// Send message as a bot, rendered in the configured parse mode. If Telegram
+// can't parse the markup, the message is sent again without formatting
+func (self *bot) send_message(
+    chat_id int, message Text,
+) (*tg_api.Message, error) {
+    parse_mode := self.cfg().ParseMode
+    text, entities := message.Render(parse_mode)
+    sent, err := self.client.SendMessage(
+        self.ctx,
+        tg_api.SendMessage{
+            ChatId:    chat_id,
+            Text:      text,
+            ParseMode: parse_mode,
+            Entities:  entities,
+        },
     )
-} // <-- bot::Uri(endpoint)
+    if len(parse_mode) == 0 || !is_markup_error(err) {
+        return sent, err
+    }
 
-// Send message as a bot. Set `use_md=true` if message contains Markdown
-func (self *bot) send_message(
-    message string, use_md bool,
+    log.Println("Telegram rejected the markup, sending as plain text:", err)
+    text, entities = message.Render("")
+    return self.client.SendMessage(
+        self.ctx,
+        tg_api.SendMessage{ ChatId: chat_id, Text: text, Entities: entities },
+    )
+} // <-- bot::send_message(chat_id, message)
+
+// Make a bot edit its message, rendered in the configured parse mode
+func (self *bot) edit_message(
+    message_id int, message Text,
 ) (*tg_api.Message, error) {
-    p := tg_api.SendMessage{
-        ChatId:    self.config.ChatId,
-        Text:      message,
-        ParseMode: "",
+    parse_mode := self.cfg().ParseMode
+    text, _ := message.Render(parse_mode)
+    return self.client.EditMessageText(
+        self.ctx,
+        tg_api.EditMessageText{
+            ChatId:    self.cfg().ChatId,
+            MessageId: message_id,
+            Text:      text,
+            ParseMode: parse_mode,
+        },
+    )
+} // <-- bot::edit_message(message_id, message)
+
+// The caption as plain text, shortened to CAPTION_LIMIT if needed
+func truncate_caption(caption Text) string {
+    text := caption.String()
+    if tg_api.UTF16Len(text) <= CAPTION_LIMIT {
+        return text
+    }
+    return caption.Split(CAPTION_LIMIT - 1)[0].String() + "…"
+} // <-- truncate_caption(caption)
+
+// Upload the file as a document with the caption. If the file can't be
+// sent, the caption is sent as a message (through the outbox) instead
+func (self *bot) send_document(path string, caption string, admin bool) {
+    chat_id, routed := self.route(PlainText(caption), admin)
+    text := truncate_caption(routed)
+
+    _, err := self.client.SendDocument(
+        self.ctx,
+        tg_api.SendDocument{
+            ChatId:   chat_id,
+            Document: path,
+            Caption:  text,
+        },
+    )
+    if err == nil {
+        return
     }
 
-    if use_md {
-        p.ParseMode = tg_api.PM_MARKDOWN
+    log.Println("Could not send", path, "to Telegram:", err)
+    self.deliver(
+        Text{
+            Plain(caption + "\n"),
+            Italic(fmt.Sprintf("(could not attach %s)", filepath.Base(path))),
+        },
+        admin,
+        nil,
+    )
+} // <-- bot::send_document(path, caption, admin)
+
+// Check if the error means that Telegram could not be reached and sending
+// the message again later makes sense
+func is_transient(err error) bool {
+    var api_err *tg_api.Error
+    if !errors.As(err, &api_err) {
+        return true // Network error
     }
+    return api_err.ErrorCode == 429 || api_err.ErrorCode >= 500
+} // <-- is_transient(err)
+
+// Room for the text of a message part once it's routed
+func (self *bot) message_limit(admin bool) int {
+    _, prefix := self.route(nil, admin)
+    return MESSAGE_LIMIT - tg_api.UTF16Len(prefix.String())
+} // <-- bot::message_limit(admin)
+
+// Send all messages queued in the outbox
+func (self *bot) flush_outbox() error {
+    send := func(message Text, admin bool) ([]Text, error) {
+        // Summaries of long outages may need several messages
+        parts := message.Split(self.message_limit(admin))
+        for i, part := range parts {
+            chat_id, text := self.route(part, admin)
+            _, err := self.send_message(chat_id, text)
+            if err != nil && !is_transient(err) {
+                // Telegram will never accept this part, drop it so it does
+                // not block the rest of the queue
+                log.Printf(
+                    "Dropping a message from the outbox: %v\n%s\n",
+                    err, part.String(),
+                )
+                continue
+            }
+            if err != nil {
+                return parts[i:], err
+            }
+        }
+        return nil, nil
+    } // <-- send(message, admin)
+
+    return self.outbox.Flush(send, self.cfg().OutboxSummarize)
+} // <-- bot::flush_outbox()
+
+// Send the message, or queue it if Telegram is unreachable. Messages are
+// always delivered in order, so while the outbox is not empty new messages
+// go to its end. Messages over MESSAGE_LIMIT are split into several.
+// `mentions` lists the words to turn into mentions of Telegram users (see
+// render_mentions), may be nil.
+// Returns the sent messages, the queued or dropped ones are not included
+func (self *bot) deliver(
+    message Text, admin bool, mentions map[string]string,
+) []*tg_api.Message {
+    var ret []*tg_api.Message
+    message = render_mentions(message, mentions, self.users)
+    for _, part := range message.Split(self.message_limit(admin)) {
+        if self.outbox.Len() != 0 {
+            self.flush_outbox()
+        }
 
-    exch_f := tg_api.ExchangeIntoWith[tg_api.Message, tg_api.SendMessage]
-    if res, err := exch_f(self.Uri("sendMessage"), p); err == nil {
-        if !res.Ok {
-            return nil, res
+        if self.outbox.Len() == 0 {
+            chat_id, text := self.route(part, admin)
+            sent, err := self.send_message(chat_id, text)
+            if err == nil {
+                ret = append(ret, sent)
+                continue
+            }
+            if !is_transient(err) {
+                log.Println("Could not send a message:", err)
+                continue
+            }
+            log.Println("Telegram is unreachable, queueing the message:", err)
+        }
+
+        if err := self.outbox.Push(part, admin); err != nil {
+            log.Println("Could not write the outbox:", err)
         }
-        return &res.Result, nil
-    } else {
-        return nil, err
     }
-} // <-- bot::send_message(message, use_md)
+    return ret
+} // <-- bot::deliver(message, admin, mentions)
 
-// Make a bot edit its message. Set `use_md=true` if message contains Markdown
-func (self *bot) edit_message(
-    message_id int, message string, use_md bool,
-) (*tg_api.Message, error) {
-    p := tg_api.EditMessageText{
-        ChatId:    self.config.ChatId,
-        MessageId: message_id,
-        Text:      message,
-        ParseMode: "",
+func (self *bot) IsRunning() bool { return self.running != BS_STOPPED }
+
+// Send the event to the output channel unless the bot is stopping
+func (self *bot) emit(event any) {
+    select {
+    case self.out <- event:
+    case <-self.ctx.Done():
+    }
+} // <-- bot::emit(event)
+
+// Wait before retrying a failed request. Honors Telegram's flood control
+func (self *bot) backoff(err error) {
+    delay := RETRY_DELAY
+    var api_err *tg_api.Error
+    if errors.As(err, &api_err) && api_err.RetryAfter > 0 {
+        delay = time.Duration(api_err.RetryAfter) * time.Second
     }
-    
-    if use_md {
-        p.ParseMode = tg_api.PM_MARKDOWN
+
+    select {
+    case <-time.After(delay):
+    case <-self.ctx.Done():
     }
+} // <-- bot::backoff(err)
 
-    exch_f := tg_api.ExchangeIntoWith[tg_api.Message, tg_api.EditMessageText]
-    if res, err := exch_f(self.Uri("editMessageText"), p); err == nil {
-        if !res.Ok {
-            return nil, res
+// Minecraft player the message should be whispered to: the author of the
+// relayed chat message it replies to
+func (self *bot) whisper_target(message *tg_api.Message) string {
+    if message.ReplyToMessage == nil {
+        return ""
+    }
+    player, _ := self.senders.Get(message.ReplyToMessage.MessageId)
+    return player
+} // <-- bot::whisper_target(message)
+
+// Split `/tell <player> <text>` into the player and the text with its
+// entities. Returns false if the message is not a /tell command, and an
+// empty player if the command is malformed
+func parse_tell(
+    text string, entities []tg_api.MessageEntity,
+) (string, string, []tg_api.MessageEntity, bool) {
+    argv := strings.SplitN(text, " ", 3)
+    if argv[0] != "/tell" {
+        return "", "", nil, false
+    }
+    if len(argv) != 3 || len(strings.TrimSpace(argv[2])) == 0 {
+        return "", "", nil, true
+    }
+
+    start := len(text) - len(argv[2])
+    return argv[1],
+        argv[2],
+        tg_api.SliceEntities(text, entities, start, len(text)),
+        true
+} // <-- parse_tell(text, entities)
+
+// Event for an edited message, nil if the edit should not be relayed
+func (self *bot) edited_message(edited *tg_api.Message) *OutputEventEditMessage {
+    if edited.Chat.Id != self.cfg().ChatId {
+        return nil
+    }
+
+    // Media messages can only have their caption edited
+    text, entities := edited.Text, edited.Entities
+    if len(text) == 0 {
+        text, entities = edited.Caption, edited.CaptionEntities
+    }
+    if len(text) == 0 {
+        return nil
+    }
+
+    // The recipient comes from the message itself: the history may not
+    // have it, and a whisper must never end up broadcast
+    whisper := self.whisper_target(edited)
+    if player, body, body_entities, is_tell := parse_tell(
+        text, entities,
+    ); is_tell {
+        if len(player) == 0 {
+            return nil
         }
-        return &res.Result, nil
-    } else {
-        return nil, err
+        text, entities, whisper = body, body_entities, player
     }
-} // <-- bot::edit_message(message_id, message, use_md)
 
-func (self *bot) IsRunning() bool { return self.running != BS_STOPPED }
+    ret := OutputEventEditMessage{
+        Username: edited.From.Username,
+        Message:  text,
+        Entities: entities,
+        Whisper:  whisper,
+    }
+    if original, found := self.relayed.Get(edited.MessageId); found {
+        if original.Text == text {
+            return nil // Only the formatting or the media has changed
+        }
+        ret.Original = original.Text
+        if len(ret.Whisper) == 0 {
+            ret.Whisper = original.Whisper
+        }
+        original.Text = text
+        self.relayed.Put(edited.MessageId, original)
+    }
+    return &ret
+} // <-- bot::edited_message(edited)
+
+// Delete the message from the chat and forget it
+func (self *bot) delete_message(message *tg_api.Message) error {
+    err := self.client.DeleteMessage(
+        self.ctx,
+        tg_api.DeleteMessage{
+            ChatId:    message.Chat.Id,
+            MessageId: message.MessageId,
+        },
+    )
+    if err != nil {
+        return err
+    }
 
-func (self *bot) handle_updates() {
-    type Params struct {
-        Offset int `json:"offset"`
-    } // <-- var params
+    self.senders.Delete(message.MessageId)
+    self.relayed.Delete(message.MessageId)
+    return nil
+} // <-- bot::delete_message(message)
 
-    params := Params{ 0 }
+func (self *bot) handle_updates() {
+    params := tg_api.GetUpdates{ Offset: 0, Timeout: POLL_TIMEOUT }
 
     updateMessage := func(message *tg_api.Message) any {
-        if message.Chat.Id != self.config.ChatId {
+        if message.Chat.Id != self.cfg().ChatId {
             return nil
         }
+        self.users.Put(message.From)
 
         if len(message.Text) == 0 {
-            return nil
+            media := describe_media(message)
+            if len(media) == 0 {
+                return nil
+            }
+
+            ret := OutputEventMessage{
+                Username: message.From.Username,
+                Message:  message.Caption,
+                Entities: message.CaptionEntities,
+                Media:    media,
+                Reply:    make_reply(message),
+                Whisper:  self.whisper_target(message),
+            }
+            if self.cfg().MediaLinks {
+                ret.Link = media_link(message)
+            }
+            return ret
         }
 
-        admin := message.From.Username == self.config.AdminUsername
+        admin := message.From.Username == self.cfg().AdminUsername
 
         switch message.Text {
         case "/players":
@@ -160,6 +553,51 @@ func (self *bot) handle_updates() {
             if admin {
                 return OutputEventKillServer{}
             }
+        case "/reload":
+            if admin {
+                return OutputEventReload{}
+            }
+        case "/delete":
+            if admin {
+                target := message.ReplyToMessage
+                if target == nil {
+                    return OutputEventUserError{
+                        Message: "Reply with /delete to the message to delete",
+                    }
+                }
+                if err := self.delete_message(target); err != nil {
+                    log.Println("Could not delete a message:", err)
+                    return OutputEventUserError{
+                        Message: "Could not delete the message: " + err.Error(),
+                    }
+                }
+                // The text is not logged, it was deleted for a reason
+                log.Printf(
+                    "Message %d by @%s deleted by @%s\n",
+                    target.MessageId,
+                    target.From.Username,
+                    message.From.Username,
+                )
+                // Clean up the command too, it's fine if this fails
+                self.delete_message(message)
+                return nil
+            }
+        }
+
+        if player, text, entities, is_tell := parse_tell(
+            message.Text, message.Entities,
+        ); is_tell {
+            if len(player) == 0 {
+                return OutputEventUserError{
+                    Message: "Usage: /tell <minecraft_nickname> <message>",
+                }
+            }
+            return OutputEventMessage{
+                Username: message.From.Username,
+                Message:  text,
+                Entities: entities,
+                Whisper:  player,
+            }
         }
 
         if strings.HasPrefix(message.Text, "/iamthe") {
@@ -183,43 +621,64 @@ func (self *bot) handle_updates() {
         return OutputEventMessage{
             Username: message.From.Username,
             Message:  message.Text,
+            Entities: message.Entities,
+            Reply:    make_reply(message),
+            Whisper:  self.whisper_target(message),
         }
     } // <-- updateMessage(message)
 
-    exch_f := tg_api.ExchangeIntoWith[[]tg_api.Update, Params]
     for {
         if self.running != BS_RUNNING {
             break
         }
 
-        if res, err := exch_f(self.Uri("getUpdates"), params); err == nil {
-            if !res.Ok {
-                self.out <- OutputEventAPIError{ res }
-                continue
+        updates, err := self.client.GetUpdates(self.ctx, params)
+        if err != nil {
+            if self.ctx.Err() != nil {
+                continue // The bot is stopping
             }
 
-            for _, update := range res.Result {
-                if params.Offset < update.UpdateId + 1 {
-                    params.Offset = update.UpdateId + 1
-                }
+            var api_err *tg_api.Error
+            if errors.As(err, &api_err) {
+                self.emit(OutputEventAPIError{ err })
+            } else {
+                self.emit(OutputEventRequestError{ err })
+            }
+            self.backoff(err)
+            continue
+        }
 
-                if update.Message != nil {
-                    if e := updateMessage(update.Message); e != nil {
-                        self.out <- e
-                    }
+        // Telegram is reachable again, ask for the backlog to be sent
+        if self.outbox.Len() != 0 {
+            select {
+            case self.outbox_ready <- struct{}{}:
+            default:
+            }
+        }
+
+        for _, update := range updates {
+            if params.Offset < update.UpdateId + 1 {
+                params.Offset = update.UpdateId + 1
+            }
+
+            if update.Message != nil {
+                e := updateMessage(update.Message)
+                if chat, is_chat := e.(OutputEventMessage); is_chat {
+                    self.relayed.Put(
+                        update.Message.MessageId,
+                        relayed_message{ Text: chat.Message, Whisper: chat.Whisper },
+                    )
                 }
+                if e != nil {
+                    self.emit(e)
+                }
+            }
 
-                if update.EditedMessage != nil && len(update.EditedMessage.Text) != 0 {
-                    if update.EditedMessage.Chat.Id == self.config.ChatId {
-                        self.out <- OutputEventEditMessage{
-                            Username: update.EditedMessage.From.Username,
-                            Message:  update.EditedMessage.Text,
-                        }
-                    }
+            if edited := update.EditedMessage; edited != nil {
+                if e := self.edited_message(edited); e != nil {
+                    self.emit(*e)
                 }
             }
-        } else {
-            self.out <- OutputEventRequestError{ err }
         }
     }
     self.wg.Done()
@@ -229,18 +688,39 @@ func (self *bot) handle_updates() {
 func (self *bot) handle_inputs() {
     handler:
     for {
-        ie, open := <-self.in
-        if !open {
+        var ie any
+        select {
+        case <-self.outbox_ready:
+            self.flush_outbox()
             continue
+        case event, open := <-self.in:
+            if !open {
+                continue
+            }
+            ie = event
         }
 
         switch event := ie.(type) {
         case input_event_terminate:
+            // Last chance to deliver what Telegram didn't accept before
+            self.flush_outbox()
             break handler
         case InputEventSendMessage:
-            self.send_message(event.Message, false)
+            message := event.Text
+            if len(message) == 0 {
+                message = PlainText(event.Message)
+            }
+            sent := self.deliver(message, event.Admin, event.Mentions)
+            if len(event.Username) != 0 {
+                for _, part := range sent {
+                    self.senders.Put(part.MessageId, event.Username)
+                }
+            }
+        case InputEventSendDocument:
+            self.send_document(event.Path, event.Caption, event.Admin)
         }
     }
+    close(self.inputs_done)
     self.wg.Done()
     log.Println("Exit bot.bot::handle_inputs()")
 } // <-- bot::handle_input()
@@ -252,6 +732,8 @@ func (self *bot) Start() {
     }
 
     self.running = BS_RUNNING
+    self.ctx, self.cancel = context.WithCancel(context.Background())
+    self.inputs_done = make(chan struct{})
     self.wg.Add(2)
     go self.handle_updates()
     go self.handle_inputs()
@@ -265,6 +747,14 @@ func (self *bot) Stop() {
 
     self.running = BS_STOPPING
     self.in <- input_event_terminate{}
+    // Let the input handler flush the outbox before the requests are
+    // cancelled
+    select {
+    case <-self.inputs_done:
+    case <-time.After(STOP_FLUSH_TIMEOUT):
+        log.Println("Could not flush the outbox in time")
+    }
+    self.cancel()
     self.wg.Wait()
     self.running = BS_STOPPED
 } // <-- bot::Stop()
@@ -0,0 +1,128 @@
+package bot
+
+import (
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api/tgtest"
+)
+
+func TestTruncateCaption(t *testing.T) {
+    tests := []struct {
+        caption string
+        want    int
+    }{
+        { "short", 5 },
+        { strings.Repeat("a", CAPTION_LIMIT), CAPTION_LIMIT },
+        { strings.Repeat("a", CAPTION_LIMIT + 1), CAPTION_LIMIT },
+        // Non-BMP characters are two code units each
+        { strings.Repeat("😀", CAPTION_LIMIT), CAPTION_LIMIT - 1 },
+    }
+
+    for _, test := range tests {
+        got := truncate_caption(PlainText(test.caption))
+        if n := tg_api.UTF16Len(got); n != test.want {
+            t.Errorf("%.10q...: got length %d, want %d", test.caption, n, test.want)
+        }
+    }
+}
+
+const test_chat = -100
+
+// Bot running against a fake Telegram server
+func start_bot(t *testing.T) (*bot, *tgtest.Server) {
+    srv := tgtest.MakeServer("123:test")
+    t.Cleanup(srv.Close)
+
+    b, err := MakeBot(Config{
+        ApiToken: "123:test",
+        ApiUrl:   srv.Url(),
+        ChatId:   test_chat,
+    })
+    if err != nil {
+        t.Fatalf("MakeBot: %v", err)
+    }
+    b.Start()
+    t.Cleanup(b.Stop)
+    return b, srv
+} // <-- start_bot(t)
+
+// Read the bot's output events until one of type T arrives
+func wait_output[T any](t *testing.T, b *bot) T {
+    t.Helper()
+    deadline := time.After(5 * time.Second)
+    for {
+        select {
+        case event := <-b.Out():
+            if ret, is_t := event.(T); is_t {
+                return ret
+            }
+        case <-deadline:
+            var zero T
+            t.Fatalf("timed out waiting for %T", zero)
+        }
+    }
+} // <-- wait_output[T](t, b)
+
+func TestBotRelaysMessages(t *testing.T) {
+    b, srv := start_bot(t)
+    alice := tg_api.User{ Id: 42, FirstName: "Alice", Username: "alice" }
+
+    msg := srv.InjectMessage(test_chat, alice, "hello")
+    got := wait_output[OutputEventMessage](t, b)
+    if got.Username != "alice" || got.Message != "hello" {
+        t.Errorf("message = %+v, want \"hello\" from alice", got)
+    }
+
+    if _, err := srv.InjectEdit(msg.MessageId, "hello there"); err != nil {
+        t.Fatal(err)
+    }
+    edit := wait_output[OutputEventEditMessage](t, b)
+    if edit.Username != "alice" || edit.Message != "hello there" ||
+        edit.Original != "hello" {
+        t.Errorf("edit = %+v, want \"hello\" edited to \"hello there\"", edit)
+    }
+
+    b.In() <- InputEventSendMessage{ Message: "Steve joined the game" }
+    sent, err := srv.WaitSent(1, 5 * time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if sent[0].Text != "Steve joined the game" || sent[0].Chat.Id != test_chat {
+        t.Errorf("sent = %+v, want the message in the chat", sent[0])
+    }
+} // <-- TestBotRelaysMessages
+
+func TestBotQueuesOnFloodControl(t *testing.T) {
+    b, srv := start_bot(t)
+
+    srv.Fail("sendMessage", tg_api.Error{
+        ErrorCode:   429,
+        Description: "Too Many Requests: retry after 1",
+        RetryAfter:  1,
+    })
+    b.In() <- InputEventSendMessage{ Message: "queued" }
+    deadline := time.Now().Add(5 * time.Second)
+    for b.outbox.Len() != 1 {
+        if time.Now().After(deadline) {
+            t.Fatalf("outbox length = %d, want 1", b.outbox.Len())
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    if sent := srv.Sent(); len(sent) != 0 {
+        t.Fatalf("sent = %+v, want nothing while rate limited", sent)
+    }
+
+    // A successful poll means Telegram is reachable again
+    srv.InjectMessage(test_chat, tg_api.User{ Id: 42, Username: "alice" }, "hi")
+    wait_output[OutputEventMessage](t, b)
+    sent, err := srv.WaitSent(1, 5 * time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if sent[0].Text != "queued" {
+        t.Errorf("sent = %+v, want the queued message", sent[0])
+    }
+} // <-- TestBotQueuesOnFloodControl
@@ -1,13 +1,45 @@
 package bot
 
+import (
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+)
+
 type OutputEventMessage struct {
     Username string
+    // Text or caption, may be empty for media messages
     Message  string
+    // Formatting, links... of the text
+    Entities []tg_api.MessageEntity
+    // Description of the attached media (photo, sticker...), if any
+    Media    string
+    // Link to open the media in, if enabled in the config
+    Link     string
+    // The message is a reply
+    Reply    *Reply
+    // Minecraft player to deliver the message to privately. Empty for
+    // messages to everyone
+    Whisper  string
 } // <-- struct OutputEventMessage
 
+// What a message replies to
+type Reply struct {
+    // Telegram username of the replied message's author. Empty for replies
+    // to the bot's messages
+    Username string
+    // The replied message's text or the quoted part of it
+    Text     string
+} // <-- struct Reply
+
 type OutputEventEditMessage struct {
     Username string
     Message  string
+    // Formatting, links... of the text
+    Entities []tg_api.MessageEntity
+    // Text of the message before the edit, empty if the bot doesn't
+    // remember it
+    Original string
+    // The original message was whispered to this Minecraft player
+    Whisper  string
 } // <-- sturct OutputEventEditMessage
 
 type OutputEventCommand struct {
@@ -23,6 +55,9 @@ type OutputEventListPlayers struct {}
 
 type OutputEventKillServer struct {}
 
+// Admin asked to re-read the config
+type OutputEventReload struct {}
+
 type OutputEventUserError struct {
     Message string
 } // <-- struct OutputEventUserError
@@ -39,5 +74,24 @@ type OutputEventAPIError struct {
 type input_event_terminate struct {}
 
 type InputEventSendMessage struct {
-    Message string
+    // Plain text of the message, used if `Text` is empty
+    Message  string
+    // Formatted message
+    Text     Text
+    // Send to the admin chat instead of the main one
+    Admin    bool
+    // Minecraft player who wrote the message, if it's relayed chat. Replies
+    // to the message are then whispered to the player
+    Username string
+    // Words of the message (e.g. "@Steve") to turn into mentions of the
+    // Telegram users (usernames without the @) they refer to
+    Mentions map[string]string
 } // <-- struct InputEventSendMessage
+
+type InputEventSendDocument struct {
+    // File to upload
+    Path    string
+    Caption string
+    // Send to the admin chat instead of the main one
+    Admin   bool
+} // <-- struct InputEventSendDocument
@@ -0,0 +1,256 @@
+// format.go
+// Formatted messages and their rendering in the Telegram parse modes
+package bot
+
+import (
+    "fmt"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+)
+
+// Formatting of a part of a message
+const (
+    FMT_PLAIN   = iota
+    FMT_BOLD    = iota
+    FMT_ITALIC  = iota
+    // Inline code
+    FMT_CODE    = iota
+    // Code block
+    FMT_PRE     = iota
+    // Mention of a Telegram user by their ID. For internal use only
+    fmt_mention = iota
+)
+
+// Part of a formatted message
+type Span struct {
+    Text   string `json:"text"`
+    Format int    `json:"format,omitempty"`
+    // The mentioned user, for fmt_mention
+    UserId int    `json:"user_id,omitempty"`
+} // <-- struct Span
+
+func Plain(text string) Span  { return Span{ Text: text } }
+func Bold(text string) Span   { return Span{ Text: text, Format: FMT_BOLD } }
+func Italic(text string) Span { return Span{ Text: text, Format: FMT_ITALIC } }
+func Code(text string) Span   { return Span{ Text: text, Format: FMT_CODE } }
+func Pre(text string) Span    { return Span{ Text: text, Format: FMT_PRE } }
+
+// Formatted message. The text of the spans is never escaped by the caller,
+// this is done when the message is rendered for the parse mode
+type Text []Span
+
+// Unformatted message
+func PlainText(text string) Text {
+    return Text{ Plain(text) }
+} // <-- PlainText(text)
+
+// The message without the formatting
+func (self Text) String() string {
+    var sb strings.Builder
+    for _, span := range self {
+        sb.WriteString(span.Text)
+    }
+    return sb.String()
+} // <-- Text::String()
+
+// Copy of the message without the trailing line breaks
+func (self Text) TrimNewlines() Text {
+    ret := append(Text{}, self...)
+    for len(ret) != 0 {
+        last := &ret[len(ret) - 1]
+        last.Text = strings.TrimRight(last.Text, "\n")
+        if len(last.Text) != 0 {
+            break
+        }
+        ret = ret[:len(ret) - 1]
+    }
+    return ret
+} // <-- Text::TrimNewlines()
+
+// Join the neighbouring spans with the same formatting and drop the empty
+// ones. Neighbouring italic spans would be ambiguous in MarkdownV2
+func (self Text) normalize() Text {
+    var ret Text
+    for _, span := range self {
+        if len(span.Text) == 0 {
+            continue
+        }
+        if n := len(ret); n != 0 && ret[n - 1].Format == span.Format &&
+            span.Format != fmt_mention {
+            ret[n - 1].Text += span.Text
+            continue
+        }
+        ret = append(ret, span)
+    }
+    return ret
+} // <-- Text::normalize()
+
+// Render the message for the parse mode (tg_api.PM_*). Without a parse mode
+// the formatting is dropped, and the mentions are returned as entities
+func (self Text) Render(parse_mode string) (string, []tg_api.MessageEntity) {
+    var sb strings.Builder
+    var entities []tg_api.MessageEntity
+    for _, span := range self.normalize() {
+        switch parse_mode {
+        case tg_api.PM_MARKDOWN:
+            sb.WriteString(span.markdown())
+        case tg_api.PM_HTML:
+            sb.WriteString(span.html())
+        default:
+            if span.Format == fmt_mention {
+                entities = append(entities, tg_api.MessageEntity{
+                    Type:   tg_api.ET_TEXT_MENTION,
+                    Offset: tg_api.UTF16Len(sb.String()),
+                    Length: tg_api.UTF16Len(span.Text),
+                    User:   &tg_api.User{ Id: span.UserId },
+                })
+            }
+            sb.WriteString(span.Text)
+        }
+    }
+    return sb.String(), entities
+} // <-- Text::Render(parse_mode)
+
+// Characters to escape in MarkdownV2 text
+var markdown_escaper = strings.NewReplacer(
+    "\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]",
+    "(", "\\(", ")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>",
+    "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|", "\\|",
+    "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
+
+// Characters to escape in MarkdownV2 code
+var markdown_code_escaper = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+
+// Characters to escape in MarkdownV2 link URLs
+var markdown_url_escaper = strings.NewReplacer("\\", "\\\\", ")", "\\)")
+
+var html_escaper = strings.NewReplacer(
+    "&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;",
+)
+
+func (self *Span) markdown() string {
+    switch self.Format {
+    case FMT_BOLD:
+        return "*" + markdown_escaper.Replace(self.Text) + "*"
+    case FMT_ITALIC:
+        return "_" + markdown_escaper.Replace(self.Text) + "_"
+    case FMT_CODE:
+        return "`" + markdown_code_escaper.Replace(self.Text) + "`"
+    case FMT_PRE:
+        return "```\n" + markdown_code_escaper.Replace(self.Text) + "\n```"
+    case fmt_mention:
+        return fmt.Sprintf(
+            "[%s](%s)",
+            markdown_escaper.Replace(self.Text),
+            markdown_url_escaper.Replace(mention_url(self.UserId)),
+        )
+    }
+    return markdown_escaper.Replace(self.Text)
+} // <-- Span::markdown()
+
+func (self *Span) html() string {
+    text := html_escaper.Replace(self.Text)
+    switch self.Format {
+    case FMT_BOLD:
+        return "<b>" + text + "</b>"
+    case FMT_ITALIC:
+        return "<i>" + text + "</i>"
+    case FMT_CODE:
+        return "<code>" + text + "</code>"
+    case FMT_PRE:
+        return "<pre>" + text + "</pre>"
+    case fmt_mention:
+        return fmt.Sprintf(`<a href="%s">%s</a>`, mention_url(self.UserId), text)
+    }
+    return text
+} // <-- Span::html()
+
+// Link that mentions the user
+func mention_url(user_id int) string {
+    return fmt.Sprintf("tg://user?id=%d", user_id)
+} // <-- mention_url(user_id)
+
+// Part of the message between the byte offsets of its text
+func (self Text) slice(start int, end int) Text {
+    var ret Text
+    offset := 0
+    for _, span := range self {
+        from := max(start, offset) - offset
+        to := min(end, offset + len(span.Text)) - offset
+        offset += len(span.Text)
+        if from < to {
+            span.Text = span.Text[from:to]
+            ret = append(ret, span)
+        }
+    }
+    return ret
+} // <-- Text::slice(start, end)
+
+// Split the message into parts of at most `limit` UTF-16 code units of text
+// (which is how Telegram measures messages). Parts end at a line break or a
+// space if there is one in the second half of the part. Formatting carries
+// over, so a code block cut in two becomes two code blocks, and mentions are
+// never cut
+func (self Text) Split(limit int) []Text {
+    text := self.String()
+    var mentions [][2]int
+    offset := 0
+    for _, span := range self {
+        if span.Format == fmt_mention {
+            mentions = append(
+                mentions, [2]int{ offset, offset + len(span.Text) },
+            )
+        }
+        offset += len(span.Text)
+    }
+
+    var ret []Text
+    start := 0
+    for tg_api.UTF16Len(text[start:]) > limit {
+        // The longest prefix that fits
+        end, size := start, 0
+        for end < len(text) {
+            // Invalid bytes decode as one-byte U+FFFD
+            r, width := utf8.DecodeRuneInString(text[end:])
+            n := 1
+            if r > 0xFFFF {
+                n = 2
+            }
+            if size + n > limit {
+                break
+            }
+            size += n
+            end += width
+        }
+        if end == start {
+            // Not even one character fits, send it anyway
+            _, width := utf8.DecodeRuneInString(text[end:])
+            end += width
+        }
+
+        next := end
+        half := start + (end - start) / 2
+        if i := strings.LastIndexByte(text[half:end], '\n'); i >= 0 {
+            end, next = half + i, half + i + 1
+        } else if i := strings.LastIndexByte(text[half:end], ' '); i >= 0 {
+            end, next = half + i, half + i + 1
+        }
+        for _, mention := range mentions {
+            if mention[0] < end && end < mention[1] && mention[0] > start {
+                end, next = mention[0], mention[0]
+            }
+        }
+
+        if part := self.slice(start, end).normalize(); len(part) != 0 {
+            ret = append(ret, part)
+        }
+        start = next
+    }
+    if part := self.slice(start, len(text)).normalize(); len(part) != 0 {
+        ret = append(ret, part)
+    }
+    return ret
+} // <-- Text::Split(limit)
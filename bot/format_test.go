@@ -0,0 +1,130 @@
+package bot
+
+import (
+    "slices"
+    "strings"
+    "testing"
+
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+)
+
+func TestTextSplit(t *testing.T) {
+    mention := Span{ Text: "@Steve", Format: fmt_mention, UserId: 42 }
+    tests := []struct {
+        name  string
+        text  Text
+        limit int
+        want  []string
+    }{
+        { "short", PlainText("hello"), 10, []string{ "hello" } },
+        { "empty", Text{}, 10, nil },
+        {
+            "at space", PlainText("aaaa bbbb cccc"), 10,
+            []string{ "aaaa bbbb", "cccc" },
+        },
+        {
+            "newline over space", PlainText("aa bb\ncc dd ee"), 10,
+            []string{ "aa bb", "cc dd ee" },
+        },
+        { "hard cut", PlainText("abcdefghij"), 4, []string{ "abcd", "efgh", "ij" } },
+        {
+            // Surrogate pairs count twice and are never cut in half
+            "surrogate pairs", PlainText("😀😀😀"), 3,
+            []string{ "😀", "😀", "😀" },
+        },
+        {
+            // Invalid bytes are one character each
+            "invalid utf-8", PlainText(strings.Repeat("\xff", 10)), 4,
+            []string{ "\xff\xff\xff\xff", "\xff\xff\xff\xff", "\xff\xff" },
+        },
+        {
+            "mention on the cut", Text{ Plain("abcdefg"), mention }, 10,
+            []string{ "abcdefg", "@Steve" },
+        },
+        { "too small limit", PlainText("😀a"), 1, []string{ "😀", "a" } },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            var got []string
+            for _, part := range test.text.Split(test.limit) {
+                got = append(got, part.String())
+                if n := tg_api.UTF16Len(part.String()); n > max(test.limit, 2) {
+                    t.Errorf("part %q is %d long", part.String(), n)
+                }
+            }
+            if !slices.Equal(got, test.want) {
+                t.Errorf("got %q, want %q", got, test.want)
+            }
+        })
+    }
+}
+
+func TestTextSplitKeepsFormatting(t *testing.T) {
+    text := Text{ Bold("name"), Plain(":\n"), Pre("line 1\nline 2\nline 3") }
+    parts := text.Split(14)
+    want := []Text{
+        { Bold("name"), Plain(":\n"), Pre("line 1") },
+        { Pre("line 2\nline 3") },
+    }
+    if !slices.EqualFunc(parts, want, slices.Equal) {
+        t.Errorf("got %v, want %v", parts, want)
+    }
+}
+
+func TestTextSplitLongInvalidLine(t *testing.T) {
+    text := PlainText(strings.Repeat("\xff", 5000))
+    parts := text.Split(MESSAGE_LIMIT)
+    if len(parts) != 2 || len(parts[0].String()) != MESSAGE_LIMIT {
+        t.Errorf("got %d parts", len(parts))
+    }
+}
+
+func TestTextRender(t *testing.T) {
+    text := Text{
+        Bold("cool_guy_"),
+        Plain(": 1+1=2! (a) [b] `c` \\ <d>&"),
+        Italic(""),
+        Italic("x."),
+        Italic("y"),
+        Code("a`b\\c_"),
+        Span{ Text: "@Steve", Format: fmt_mention, UserId: 42 },
+    }
+    tests := []struct {
+        parse_mode string
+        want       string
+    }{
+        {
+            tg_api.PM_MARKDOWN,
+            "*cool\\_guy\\_*: 1\\+1\\=2\\! \\(a\\) \\[b\\] \\`c\\` \\\\ <d\\>&" +
+                "_x\\.y_`a\\`b\\\\c_`[@Steve](tg://user?id=42)",
+        },
+        {
+            tg_api.PM_HTML,
+            "<b>cool_guy_</b>: 1+1=2! (a) [b] `c` \\ &lt;d&gt;&amp;" +
+                "<i>x.y</i><code>a`b\\c_</code>" +
+                `<a href="tg://user?id=42">@Steve</a>`,
+        },
+        { "", "cool_guy_: 1+1=2! (a) [b] `c` \\ <d>&x.ya`b\\c_@Steve" },
+    }
+
+    for _, test := range tests {
+        got, entities := text.Render(test.parse_mode)
+        if got != test.want {
+            t.Errorf("%q: got %q, want %q", test.parse_mode, got, test.want)
+        }
+        if len(test.parse_mode) != 0 {
+            continue
+        }
+        want := tg_api.MessageEntity{
+            Type:   tg_api.ET_TEXT_MENTION,
+            Offset: tg_api.UTF16Len(got) - 6,
+            Length: 6,
+            User:   &tg_api.User{ Id: 42 },
+        }
+        if len(entities) != 1 || entities[0].Offset != want.Offset ||
+            entities[0].Length != want.Length || entities[0].User.Id != 42 {
+            t.Errorf("got entities %+v, want %+v", entities, want)
+        }
+    }
+}
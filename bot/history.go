@@ -0,0 +1,71 @@
+// history.go
+// Bounded memory of the messages the bot has seen or sent
+package bot
+
+import (
+    "slices"
+    "sync"
+)
+
+// Number of messages remembered by each history
+const HISTORY_SIZE = 1000
+
+// Telegram message relayed to Minecraft
+type relayed_message struct {
+    // Text or caption as it was shown in Minecraft
+    Text    string
+    // The player it was whispered to, if any
+    Whisper string
+} // <-- struct relayed_message
+
+// Values attached to Telegram message IDs. Only the last `limit` messages
+// are remembered, older ones are forgotten
+type history[V any] struct {
+    mu     sync.Mutex
+    limit  int
+    // Message IDs in the order they were added
+    order  []int
+    values map[int]V
+} // <-- struct history
+
+func make_history[V any](limit int) *history[V] {
+    return &history[V]{ limit: limit, values: make(map[int]V) }
+} // <-- make_history(limit)
+
+// Remember the value for the message
+func (self *history[V]) Put(message_id int, value V) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    if _, found := self.values[message_id]; !found {
+        self.order = append(self.order, message_id)
+    }
+    self.values[message_id] = value
+
+    for len(self.order) > self.limit {
+        delete(self.values, self.order[0])
+        self.order = self.order[1:]
+    }
+} // <-- history::Put(message_id, value)
+
+// Look up the value for the message
+func (self *history[V]) Get(message_id int) (V, bool) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    value, found := self.values[message_id]
+    return value, found
+} // <-- history::Get(message_id)
+
+// Forget the message
+func (self *history[V]) Delete(message_id int) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    if _, found := self.values[message_id]; !found {
+        return
+    }
+    delete(self.values, message_id)
+    self.order = slices.DeleteFunc(
+        self.order, func(id int) bool { return id == message_id },
+    )
+} // <-- history::Delete(message_id)
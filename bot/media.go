@@ -0,0 +1,73 @@
+// media.go
+// Descriptions of the non-text messages relayed to Minecraft
+package bot
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+)
+
+// Short description of the media attached to the message (without the
+// caption), e.g. "photo", "sticker 😀" or "poll: question". Empty for plain
+// text messages
+func describe_media(message *tg_api.Message) string {
+    switch {
+    case len(message.Photo) != 0:
+        return "photo"
+    case message.Sticker != nil:
+        if len(message.Sticker.Emoji) != 0 {
+            return "sticker " + message.Sticker.Emoji
+        }
+        return "sticker"
+    case message.Animation != nil:
+        return "GIF"
+    case message.Voice != nil:
+        return "voice " + format_duration(message.Voice.Duration)
+    case message.Poll != nil:
+        return "poll: " + message.Poll.Question
+    case message.Location != nil:
+        return "location"
+    case message.Document != nil:
+        if len(message.Document.FileName) != 0 {
+            return "file " + message.Document.FileName
+        }
+        return "file"
+    }
+    return ""
+} // <-- describe_media(message)
+
+// Link to open the media in: the location on a map, or the message itself in
+// Telegram. Empty if the message can't be linked to (e.g. in a basic group)
+func media_link(message *tg_api.Message) string {
+    if loc := message.Location; loc != nil {
+        return fmt.Sprintf(
+            "https://www.openstreetmap.org/?mlat=%f&mlon=%f#map=16/%f/%f",
+            loc.Latitude, loc.Longitude, loc.Latitude, loc.Longitude,
+        )
+    }
+    return message_link(message.Chat, message.MessageId)
+} // <-- media_link(message)
+
+// t.me link to a message in a public chat or a supergroup
+func message_link(chat tg_api.Chat, message_id int) string {
+    if len(chat.Username) != 0 {
+        return fmt.Sprintf("https://t.me/%s/%d", chat.Username, message_id)
+    }
+
+    // Supergroup and channel IDs are -100 followed by the internal ID that
+    // private links use
+    id := strconv.Itoa(chat.Id)
+    internal, is_super := strings.CutPrefix(id, "-100")
+    if is_super && len(internal) != 0 {
+        return fmt.Sprintf("https://t.me/c/%s/%d", internal, message_id)
+    }
+    return ""
+} // <-- message_link(chat, message_id)
+
+// m:ss
+func format_duration(seconds int) string {
+    return fmt.Sprintf("%d:%02d", seconds / 60, seconds % 60)
+} // <-- format_duration(seconds)
@@ -0,0 +1,93 @@
+// mentions.go
+// Mentions of Telegram users in the messages relayed from Minecraft
+package bot
+
+import (
+    "strings"
+    "sync"
+
+    "github.com/gregthemadmonk/mctg-server-bot/server"
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+)
+
+// Telegram user IDs by username (lowercase), learned from the messages in
+// the chat. Needed to mention users without relying on their usernames
+type user_ids struct {
+    mu  sync.Mutex
+    ids map[string]int
+} // <-- struct user_ids
+
+func make_user_ids() *user_ids {
+    return &user_ids{ ids: make(map[string]int) }
+} // <-- make_user_ids()
+
+// Remember the user's ID
+func (self *user_ids) Put(user tg_api.User) {
+    if len(user.Username) == 0 || user.Id == 0 || user.IsBot {
+        return
+    }
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    self.ids[strings.ToLower(user.Username)] = user.Id
+} // <-- user_ids::Put(user)
+
+// Look up the user's ID by their username
+func (self *user_ids) Get(username string) (int, bool) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    id, found := self.ids[strings.ToLower(username)]
+    return id, found
+} // <-- user_ids::Get(username)
+
+// Turn the `@name` words of the text listed in `mentions` (word -> Telegram
+// username) into mentions that notify the user: a mention span if the
+// user's ID is known, otherwise the user's `@username` is added after the
+// word. Code is left as is. `ids` may be nil
+func render_mentions(
+    text Text, mentions map[string]string, ids *user_ids,
+) Text {
+    if len(mentions) == 0 {
+        return text
+    }
+
+    var ret Text
+    for _, span := range text {
+        switch span.Format {
+        case FMT_CODE, FMT_PRE, fmt_mention:
+            ret = append(ret, span)
+            continue
+        }
+
+        part := func(text string) Span {
+            return Span{ Text: text, Format: span.Format }
+        } // <-- part(text)
+
+        last := 0
+        for _, loc := range server.MentionRegexp.FindAllStringIndex(span.Text, -1) {
+            word := span.Text[loc[0]:loc[1]]
+            username, found := mentions[word]
+            if !found || strings.EqualFold(word[1:], username) {
+                continue // Telegram notifies the user on its own
+            }
+
+            if ids != nil {
+                if id, known := ids.Get(username); known {
+                    ret = append(ret, part(span.Text[last:loc[0]]))
+                    ret = append(ret, Span{
+                        Text:   word,
+                        Format: fmt_mention,
+                        UserId: id,
+                    })
+                    last = loc[1]
+                    continue
+                }
+            }
+            spelled := span.Text[last:loc[1]] + " (@" + username + ")"
+            ret = append(ret, part(spelled))
+            last = loc[1]
+        }
+        ret = append(ret, part(span.Text[last:]))
+    }
+
+    return ret
+} // <-- render_mentions(text, mentions, ids)
@@ -0,0 +1,243 @@
+// outbox.go
+// Spool for the messages that could not be delivered to Telegram
+package bot
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// A single undelivered message
+type outbox_entry struct {
+    Time    time.Time `json:"time"`
+    Message Text      `json:"text"`
+    // Plain text message, written by the older versions of the bot
+    Legacy  string    `json:"message,omitempty"`
+    // The message is for the admins
+    Admin   bool      `json:"admin,omitempty"`
+} // <-- struct outbox_entry
+
+// Ordered queue of undelivered messages, optionally backed by a file.
+// With an empty path the queue lives in RAM only and does not survive
+// restarts
+type outbox struct {
+    mu      sync.Mutex
+    path    string
+    entries []outbox_entry
+} // <-- struct outbox
+
+// Create the outbox and load the messages left over from the previous run
+func make_outbox(path string) (*outbox, error) {
+    ret := outbox{ path: path }
+    if len(path) == 0 {
+        return &ret, nil
+    }
+
+    file, err := os.Open(path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return &ret, nil
+        }
+        return nil, err
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(nil, 1 << 20)
+    for scanner.Scan() {
+        if len(strings.TrimSpace(scanner.Text())) == 0 {
+            continue
+        }
+
+        var entry outbox_entry
+        if js_err := json.Unmarshal(scanner.Bytes(), &entry); js_err != nil {
+            return nil, fmt.Errorf("%s: %w", path, js_err)
+        }
+        if len(entry.Message) == 0 {
+            entry.Message = PlainText(entry.Legacy)
+            entry.Legacy = ""
+        }
+        ret.entries = append(ret.entries, entry)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    return &ret, nil
+} // <-- make_outbox(path)
+
+// Number of messages waiting for delivery
+func (self *outbox) Len() int {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    return len(self.entries)
+} // <-- outbox::Len()
+
+// Put the message at the end of the queue
+func (self *outbox) Push(message Text, admin bool) error {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    entry := outbox_entry{ Time: time.Now(), Message: message, Admin: admin }
+    self.entries = append(self.entries, entry)
+    if len(self.path) == 0 {
+        return nil
+    }
+
+    line, js_err := json.Marshal(entry)
+    if js_err != nil {
+        return js_err
+    }
+
+    file, err := os.OpenFile(
+        self.path, os.O_WRONLY | os.O_CREATE | os.O_APPEND, 0600,
+    )
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    _, err = fmt.Fprintf(file, "%s\n", line)
+    return err
+} // <-- outbox::Push(message, admin)
+
+// Try to deliver the queued messages in order with `send`. It returns the
+// parts of the message it could not deliver (a long message is sent in
+// several) along with the error. Delivery stops at the first error (which is
+// returned), the messages and the parts that were not delivered stay in the
+// queue.
+// With `summarize=true` the backlog is collapsed into one message (one for
+// the main chat and one for the admins)
+func (self *outbox) Flush(
+    send func(message Text, admin bool) ([]Text, error), summarize bool,
+) error {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    if len(self.entries) == 0 {
+        return nil
+    }
+
+    // Queue entries for the undelivered parts of the entry
+    unsent := func(entry outbox_entry, parts []Text) []outbox_entry {
+        var ret []outbox_entry
+        for _, part := range parts {
+            ret = append(ret, outbox_entry{
+                Time:    entry.Time,
+                Message: part,
+                Admin:   entry.Admin,
+            })
+        }
+        return ret
+    } // <-- unsent(entry, parts)
+
+    var err error
+    if summarize && len(self.entries) > 1 {
+        delivered := make(map[bool]bool)
+        // The undelivered parts of the summary replace the summarized
+        // entries
+        rest := make(map[bool][]outbox_entry)
+        for _, admin := range []bool{ false, true } {
+            var entries []outbox_entry
+            for _, entry := range self.entries {
+                if entry.Admin == admin {
+                    entries = append(entries, entry)
+                }
+            }
+
+            var parts []Text
+            switch len(entries) {
+            case 0:
+                delivered[admin] = true
+                continue
+            case 1:
+                parts, err = send(entries[0].Message, admin)
+            default:
+                parts, err = send(summarize_entries(entries), admin)
+            }
+            if err != nil {
+                rest[admin] = unsent(entries[0], parts)
+                break
+            }
+            delivered[admin] = true
+        }
+
+        var kept []outbox_entry
+        for _, admin := range []bool{ false, true } {
+            kept = append(kept, rest[admin]...)
+        }
+        for _, entry := range self.entries {
+            if !delivered[entry.Admin] && rest[entry.Admin] == nil {
+                kept = append(kept, entry)
+            }
+        }
+        self.entries = kept
+    } else {
+        for i, entry := range self.entries {
+            var parts []Text
+            if parts, err = send(entry.Message, entry.Admin); err != nil {
+                self.entries = append(
+                    unsent(entry, parts), self.entries[i + 1:]...,
+                )
+                break
+            }
+        }
+        if err == nil {
+            self.entries = nil
+        }
+    }
+
+    if save_err := self.save(); save_err != nil {
+        log.Println("Could not write the outbox:", save_err)
+    }
+    return err
+} // <-- outbox::Flush(send, summarize)
+
+// Rewrite the spool file with the current queue. Must be called with the
+// mutex locked
+func (self *outbox) save() error {
+    if len(self.path) == 0 {
+        return nil
+    }
+
+    if len(self.entries) == 0 {
+        if err := os.Remove(self.path); err != nil && !os.IsNotExist(err) {
+            return err
+        }
+        return nil
+    }
+
+    var data []byte
+    for _, entry := range self.entries {
+        line, js_err := json.Marshal(entry)
+        if js_err != nil {
+            return js_err
+        }
+        data = append(data, line...)
+        data = append(data, '\n')
+    }
+
+    // Write a copy first so that a crash mid-write does not lose the queue
+    tmp := self.path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0600); err != nil {
+        return err
+    }
+    return os.Rename(tmp, self.path)
+} // <-- outbox::save()
+
+// Collapse the backlog into a single message
+func summarize_entries(entries []outbox_entry) Text {
+    ret := Text{ Italic("While the bot was offline:"), Plain("\n") }
+    for _, entry := range entries {
+        ret = append(ret, Plain("[" + entry.Time.Local().Format("15:04") + "] "))
+        ret = append(ret, entry.Message.TrimNewlines()...)
+        ret = append(ret, Plain("\n"))
+    }
+    return ret
+} // <-- summarize_entries(entries)
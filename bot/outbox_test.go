@@ -0,0 +1,79 @@
+package bot
+
+import (
+    "errors"
+    "slices"
+    "testing"
+)
+
+// Texts of the queued messages
+func queued(ob *outbox) []string {
+    var ret []string
+    for _, entry := range ob.entries {
+        ret = append(ret, entry.Message.String())
+    }
+    return ret
+} // <-- queued(ob)
+
+func TestOutboxFlushKeepsUnsentParts(t *testing.T) {
+    ob, err := make_outbox("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    ob.Push(PlainText("first"), false)
+    ob.Push(PlainText("second"), false)
+
+    // The first message goes out in three parts, the second one fails
+    var sent []string
+    fail := func(message Text, admin bool) ([]Text, error) {
+        sent = append(sent, message.String() + " 1")
+        return []Text{
+            PlainText(message.String() + " 2"), PlainText(message.String() + " 3"),
+        }, errors.New("unreachable")
+    }
+    if err := ob.Flush(fail, false); err == nil {
+        t.Fatal("Flush didn't return the error")
+    }
+    want := []string{ "first 2", "first 3", "second" }
+    if got := queued(ob); !slices.Equal(got, want) {
+        t.Errorf("queued = %q, want %q", got, want)
+    }
+
+    sent = nil
+    ok := func(message Text, admin bool) ([]Text, error) {
+        sent = append(sent, message.String())
+        return nil, nil
+    }
+    if err := ob.Flush(ok, false); err != nil {
+        t.Fatal(err)
+    }
+    if !slices.Equal(sent, want) {
+        t.Errorf("sent = %q, want %q", sent, want)
+    }
+    if ob.Len() != 0 {
+        t.Errorf("queued = %q after a successful flush", queued(ob))
+    }
+} // <-- TestOutboxFlushKeepsUnsentParts
+
+func TestOutboxFlushSummaryKeepsUnsentParts(t *testing.T) {
+    ob, err := make_outbox("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    ob.Push(PlainText("one"), false)
+    ob.Push(PlainText("two"), false)
+    ob.Push(PlainText("alert"), true)
+
+    fail := func(message Text, admin bool) ([]Text, error) {
+        return []Text{ PlainText("summary rest") }, errors.New("unreachable")
+    }
+    if err := ob.Flush(fail, true); err == nil {
+        t.Fatal("Flush didn't return the error")
+    }
+    // The summarized entries are replaced with the unsent part, the admin
+    // message was not tried
+    want := []string{ "summary rest", "alert" }
+    if got := queued(ob); !slices.Equal(got, want) {
+        t.Errorf("queued = %q, want %q", got, want)
+    }
+} // <-- TestOutboxFlushSummaryKeepsUnsentParts
@@ -0,0 +1,41 @@
+// reply.go
+// Context of the Telegram replies relayed to Minecraft
+package bot
+
+import (
+    "strings"
+
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+)
+
+// What the message replies to: the quote if the user selected one, otherwise
+// the replied message's text. nil if the message is not a reply
+func make_reply(message *tg_api.Message) *Reply {
+    replied := message.ReplyToMessage
+    if replied == nil || replied.TopicCreated != nil {
+        return nil
+    }
+
+    var ret Reply
+    // The messages relayed from Minecraft already start with the player's
+    // name, so the bot is not named as the author
+    if !replied.From.IsBot {
+        ret.Username = replied.From.Username
+        if len(ret.Username) == 0 {
+            ret.Username = replied.From.FirstName
+        }
+    }
+
+    switch {
+    case message.Quote != nil:
+        ret.Text = message.Quote.Text
+    case len(replied.Text) != 0:
+        ret.Text = replied.Text
+    default:
+        ret.Text = replied.Caption
+        if media := describe_media(replied); len(media) != 0 {
+            ret.Text = strings.TrimSpace("[" + media + "] " + ret.Text)
+        }
+    }
+    return &ret
+} // <-- make_reply(message)
@@ -0,0 +1,67 @@
+// cli.go
+// Command-line interface
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "runtime/debug"
+)
+
+// Program version, set at build time with
+//     go build -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+const USAGE = `Usage: %s <command> [arguments]
+
+Commands:
+    run              Run the bot and the Minecraft server (default)
+    validate-config  Check the config file and report every problem found
+    replay           Dry-run the console parser over existing server logs
+    version          Print the program version
+
+Run '%s <command> -h' for the command's arguments
+`
+
+func usage() {
+    fmt.Fprintf(os.Stderr, USAGE, os.Args[0], os.Args[0])
+} // <-- usage()
+
+// Flags for the commands reading the config file
+func config_flags(name string) (*flag.FlagSet, *string) {
+    flags := flag.NewFlagSet(name, flag.ExitOnError)
+    config := flags.String("config", DEFAULT_CONFIG, "Path to the config file")
+    return flags, config
+} // <-- config_flags(name)
+
+// validate-config [--config path]
+func cmd_validate_config(args []string) int {
+    flags, config_path := config_flags("validate-config")
+    flags.Parse(args)
+
+    _, errs := load_config(*config_path, true)
+    if len(errs) == 0 {
+        fmt.Printf("%s: OK\n", *config_path)
+        return 0
+    }
+
+    for _, err := range errs {
+        fmt.Fprintf(os.Stderr, "%s: %v\n", *config_path, err)
+    }
+    return 1
+} // <-- cmd_validate_config(args)
+
+// version
+func cmd_version() int {
+    fmt.Println("mctg-server-bot", version)
+    if info, ok := debug.ReadBuildInfo(); ok {
+        for _, setting := range info.Settings {
+            if setting.Key == "vcs.revision" {
+                fmt.Println("revision", setting.Value)
+            }
+        }
+        fmt.Println(info.GoVersion)
+    }
+    return 0
+} // <-- cmd_version()
@@ -0,0 +1,215 @@
+// config.go
+// Loading and validating the configuration file
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "reflect"
+    "slices"
+    "sort"
+    "strings"
+)
+
+// Default config file location
+const DEFAULT_CONFIG = "mctg-bot-config.json"
+
+// A key the config structs don't have, e.g. left over from an older version
+var err_unknown_field = errors.New("unknown field")
+
+// Read and parse the config file, apply the overrides from the environment,
+// then check it. All problems found are returned at once. Unknown fields are
+// only errors with `strict`, otherwise they are logged and ignored
+func load_config(path string, strict bool) (Config, []error) {
+    var config Config
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return config, []error{ err }
+    }
+
+    // Check the types field by field first: json.Unmarshal only reports the
+    // first error
+    errs := check_fields("", data, reflect.TypeOf(config))
+    if !strict {
+        errs = slices.DeleteFunc(errs, func(err error) bool {
+            if !errors.Is(err, err_unknown_field) {
+                return false
+            }
+            log.Printf("%s: %v, ignoring it\n", path, err)
+            return true
+        })
+    }
+
+    if json_err := json.Unmarshal(data, &config); json_err != nil {
+        var type_err *json.UnmarshalTypeError
+        if !errors.As(json_err, &type_err) {
+            if len(errs) != 0 {
+                return config, errs // Syntax error, already reported
+            }
+            return config, []error{ json_err }
+        }
+        // The fields with bad types are skipped and already reported
+    }
+
+    // Secrets from files referenced in the config, then the environment
+    if path := config.Bot.ApiTokenFile; len(path) != 0 {
+        if secret, err := read_secret(path); err != nil {
+            errs = append(errs, fmt.Errorf("bot.api_token_file: %w", err))
+        } else {
+            config.Bot.ApiToken = secret
+        }
+    }
+    errs = append(errs, apply_env(ENV_PREFIX, reflect.ValueOf(&config).Elem())...)
+
+    // Don't report the fields with bad types twice
+    reported := func(err error) bool {
+        for _, prev := range errs {
+            field := strings.SplitN(prev.Error(), ":", 2)[0]
+            if strings.HasPrefix(err.Error(), field + ":") {
+                return true
+            }
+        }
+        return false
+    } // <-- reported(err)
+
+    for _, err := range config.Validate() {
+        if !reported(err) {
+            errs = append(errs, err)
+        }
+    }
+    return config, errs
+} // <-- load_config(path)
+
+// Check the config values
+func (self *Config) Validate() []error {
+    var errs []error
+    for _, err := range self.Bot.Validate() {
+        errs = append(errs, fmt.Errorf("bot.%w", err))
+    }
+    for _, err := range self.Server.Validate() {
+        errs = append(errs, fmt.Errorf("server.%w", err))
+    }
+    escalation := self.Server.StopWait() + self.Server.TermWait()
+    if self.ShutdownTimeout != 0 && self.ShutdownWait() <= escalation {
+        errs = append(
+            errs,
+            fmt.Errorf(
+                "shutdown_timeout: must be more than server.stop_timeout + " +
+                    "server.term_timeout (%d), or the server is killed " +
+                    "before it gets SIGTERM",
+                int(escalation.Seconds()),
+            ),
+        )
+    }
+    return errs
+} // <-- Config::Validate()
+
+// Describe the position in the JSON document
+func json_position(data []byte, offset int64) string {
+    if offset > int64(len(data)) {
+        offset = int64(len(data))
+    }
+    line := bytes.Count(data[:offset], []byte{ '\n' }) + 1
+    col  := offset - int64(bytes.LastIndexByte(data[:offset], '\n'))
+    return fmt.Sprintf("line %d, column %d", line, col)
+} // <-- json_position(data, offset)
+
+// Check that the JSON object has no unknown fields (err_unknown_field) and
+// that every field has the type the struct expects. Recurses into nested
+// structs
+func check_fields(prefix string, data []byte, typ reflect.Type) []error {
+    name := func(key string) string {
+        if len(prefix) == 0 {
+            return key
+        }
+        return prefix + "." + key
+    } // <-- name(key)
+
+    var object map[string]json.RawMessage
+    if err := json.Unmarshal(data, &object); err != nil {
+        var syntax_err *json.SyntaxError
+        if errors.As(err, &syntax_err) {
+            return []error{
+                fmt.Errorf(
+                    "%s: %w", json_position(data, syntax_err.Offset), err,
+                ),
+            }
+        }
+        if len(prefix) == 0 {
+            return []error{ fmt.Errorf("expected a JSON object") }
+        }
+        return []error{ fmt.Errorf("%s: expected an object", prefix) }
+    }
+
+    fields := make(map[string]reflect.StructField)
+    for i := range typ.NumField() {
+        field := typ.Field(i)
+        tag := json_tag(field)
+        if len(tag) == 0 {
+            continue
+        }
+        fields[tag] = field
+    }
+
+    keys := make([]string, 0, len(object))
+    for key := range object {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    var errs []error
+    for _, key := range keys {
+        field, known := fields[key]
+        if !known {
+            errs = append(errs, fmt.Errorf("%s: %w", name(key), err_unknown_field))
+            continue
+        }
+
+        if field.Type.Kind() == reflect.Struct {
+            errs = append(errs, check_fields(name(key), object[key], field.Type)...)
+            continue
+        }
+
+        value := reflect.New(field.Type)
+        if err := json.Unmarshal(object[key], value.Interface()); err != nil {
+            errs = append(
+                errs,
+                fmt.Errorf(
+                    "%s: expected %s, got %s",
+                    name(key),
+                    type_name(field.Type),
+                    strings.TrimSpace(string(object[key])),
+                ),
+            )
+        }
+    }
+    return errs
+} // <-- check_fields(prefix, data, typ)
+
+// Human-readable JSON type name for the Go type
+func type_name(typ reflect.Type) string {
+    switch typ.Kind() {
+    case reflect.String:
+        return "a string"
+    case reflect.Bool:
+        return "true or false"
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return "an integer"
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return "a non-negative integer"
+    case reflect.Float32, reflect.Float64:
+        return "a number"
+    case reflect.Slice, reflect.Array:
+        return "an array of " + strings.TrimPrefix(
+            strings.TrimPrefix(type_name(typ.Elem()), "a "), "an ",
+        ) + "s"
+    case reflect.Map, reflect.Struct:
+        return "an object"
+    }
+    return typ.String()
+} // <-- type_name(typ)
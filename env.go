@@ -0,0 +1,110 @@
+// env.go
+// Configuration overrides from the environment
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "reflect"
+    "strings"
+)
+
+// Prefix of all environment variables overriding config fields
+const ENV_PREFIX = "MCTG"
+
+// Read a secret from a file, e.g. a Docker secret or a systemd credential.
+// Trailing newlines are not part of the secret
+func read_secret(path string) (string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return "", err
+    }
+    return strings.TrimRight(string(data), "\r\n"), nil
+} // <-- read_secret(path)
+
+// Environment variable name for the config field, e.g. MCTG_BOT_API_TOKEN
+// for `bot.api_token`
+func env_name(prefix string, key string) string {
+    return prefix + "_" + strings.ToUpper(key)
+} // <-- env_name(prefix, key)
+
+// Set the field from its textual value. Strings are taken as is, string
+// lists may be a JSON array or whitespace-separated words, everything else
+// is parsed as JSON
+func set_from_env(field reflect.Value, value string) error {
+    switch {
+    case field.Kind() == reflect.String:
+        field.SetString(value)
+        return nil
+    case field.Kind() == reflect.Slice &&
+        field.Type().Elem().Kind() == reflect.String &&
+        !strings.HasPrefix(strings.TrimSpace(value), "["):
+        field.Set(reflect.ValueOf(strings.Fields(value)))
+        return nil
+    }
+
+    parsed := reflect.New(field.Type())
+    if err := json.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+        return fmt.Errorf("expected %s", type_name(field.Type()))
+    }
+    field.Set(parsed.Elem())
+    return nil
+} // <-- set_from_env(field, value)
+
+// JSON name of the struct field, empty if it has none
+func json_tag(field reflect.StructField) string {
+    tag := strings.Split(field.Tag.Get("json"), ",")[0]
+    if tag == "-" {
+        return ""
+    }
+    return tag
+} // <-- json_tag(field)
+
+// Override the struct fields from MCTG_<SECTION>_<FIELD> environment
+// variables, recursing into nested structs. MCTG_<SECTION>_<FIELD>_FILE
+// reads the value from a file instead, the plain variable wins if both are
+// set. Fields like `api_token_file` that name the file for another field
+// can't be overridden: their variable is that field's _FILE variant
+func apply_env(prefix string, value reflect.Value) []error {
+    var errs []error
+
+    typ := value.Type()
+    tags := make(map[string]bool)
+    for i := range typ.NumField() {
+        tags[json_tag(typ.Field(i))] = true
+    }
+
+    for i := range typ.NumField() {
+        tag := json_tag(typ.Field(i))
+        if len(tag) == 0 {
+            continue
+        }
+        if base, found := strings.CutSuffix(tag, "_file"); found && tags[base] {
+            continue
+        }
+
+        name  := env_name(prefix, tag)
+        field := value.Field(i)
+        if field.Kind() == reflect.Struct {
+            errs = append(errs, apply_env(name, field)...)
+            continue
+        }
+
+        if path, found := os.LookupEnv(name + "_FILE"); found {
+            if secret, err := read_secret(path); err != nil {
+                errs = append(errs, fmt.Errorf("%s_FILE: %w", name, err))
+            } else if err := set_from_env(field, secret); err != nil {
+                errs = append(errs, fmt.Errorf("%s_FILE: %w", name, err))
+            }
+        }
+
+        if env, found := os.LookupEnv(name); found {
+            if err := set_from_env(field, env); err != nil {
+                errs = append(errs, fmt.Errorf("%s: %w", name, err))
+            }
+        }
+    }
+
+    return errs
+} // <-- apply_env(prefix, value)
@@ -1,10 +1,14 @@
 package main
 
 import (
-    "encoding/json"
     "fmt"
     "log"
     "os"
+    "os/signal"
+    "reflect"
+    "strings"
+    "syscall"
+    "time"
 
     "github.com/gregthemadmonk/mctg-server-bot/bot"
     "github.com/gregthemadmonk/mctg-server-bot/server"
@@ -13,18 +17,67 @@ import (
 type Config struct {
     Bot    bot.Config    `json:"bot"`
     Server server.Config `json:"server"`
+    // Seconds to wait for the server to stop when the bot is asked to shut
+    // down (SIGINT/SIGTERM) before killing it. Must leave time for the
+    // server's stop and SIGTERM timeouts. 0 for the default
+    ShutdownTimeout uint `json:"shutdown_timeout,omitempty"`
+    // Send the errors and exceptions the server logs to the admins
+    ForwardErrors   bool `json:"forward_errors,omitempty"`
 } // <-- type Config struct
 
+// Time after the server's own stop escalation (see server.Config.StopWait
+// and TermWait) the shutdown waits by default before killing the server
+const SHUTDOWN_MARGIN = 5 * time.Second
+
+// Time to wait for the server to stop on shutdown
+func (self *Config) ShutdownWait() time.Duration {
+    if self.ShutdownTimeout == 0 {
+        return self.Server.StopWait() + self.Server.TermWait() + SHUTDOWN_MARGIN
+    }
+    return time.Duration(self.ShutdownTimeout) * time.Second
+} // <-- Config::ShutdownWait()
+
 func main() {
-    log.Println("Loading config...")
-    var config Config
+    if len(os.Args) < 2 {
+        os.Exit(cmd_run(nil))
+    }
+
+    switch os.Args[1] {
+    case "run":
+        os.Exit(cmd_run(os.Args[2:]))
+    case "validate-config":
+        os.Exit(cmd_validate_config(os.Args[2:]))
+    case "replay":
+        os.Exit(cmd_replay(os.Args[2:]))
+    case "version", "--version":
+        os.Exit(cmd_version())
+    case "help", "-h", "-help", "--help":
+        usage()
+    default:
+        fmt.Fprintf(os.Stderr, "Unknown command \"%s\"\n\n", os.Args[1])
+        usage()
+        os.Exit(2)
+    }
+} // <-- main()
+
+// run [--config path] [--workdir dir]
+func cmd_run(args []string) int {
+    flags, config_path := config_flags("run")
+    workdir := flags.String(
+        "workdir", "", "Working directory for the Minecraft server",
+    )
+    flags.Parse(args)
 
-    if cfg, err := os.ReadFile("mctg-bot-config.json"); err == nil {
-        if json_err := json.Unmarshal(cfg, &config); json_err != nil {
-            log.Fatalln("Could not parse mctg-bot-config.json:", json_err)
+    log.Println("Loading config...")
+    config, errs := load_config(*config_path, false)
+    if len(errs) != 0 {
+        for _, err := range errs {
+            log.Printf("%s: %v\n", *config_path, err)
         }
-    } else {
-        log.Fatalln("Could not load mctg-bot-config.json:", err)
+        log.Fatalln("Could not load", *config_path)
+    }
+    if len(*workdir) != 0 {
+        config.Server.Workdir = *workdir
     }
 
     log.Println("Initializing the Telegram bot...")
@@ -37,13 +90,51 @@ func main() {
         log.Fatalln("Could not initialize server:", srv_err)
     }
 
-    tg_msg_name := func (usr string) string {
-        tg_name := srv.ReverseRename(usr)
-        if tg_name == usr {
-            return usr
+    // Re-read the config and apply what can be applied without a restart
+    reload := func() string {
+        log.Println("Reloading config...")
+        new_config, errs := load_config(*config_path, false)
+        if len(errs) != 0 {
+            msg := "Could not reload the config, keeping the old one:"
+            for _, err := range errs {
+                log.Printf("%s: %v\n", *config_path, err)
+                msg += fmt.Sprintf("\n* %v", err)
+            }
+            return msg
+        }
+        if len(*workdir) != 0 {
+            new_config.Server.Workdir = *workdir
+        }
+
+        restart := restart_fields(
+            "", reflect.ValueOf(config), reflect.ValueOf(new_config),
+        )
+        thebot.Reload(new_config.Bot)
+        srv.Reload(new_config.Server)
+        config = new_config
+
+        if len(restart) == 0 {
+            return "Config reloaded"
         }
-        return fmt.Sprintf("%s (%s)", usr, tg_name)
-    } // <-- tg_msg_name(usr)
+        return fmt.Sprintf(
+            "Config reloaded. These changes need a restart to take effect: %s",
+            strings.Join(restart, ", "),
+        )
+    } // <-- reload()
+
+    // SIGHUP re-reads the config, like /reload
+    reload_c := make(chan os.Signal, 1)
+    signal.Notify(reload_c, syscall.SIGHUP)
+
+    // SIGINT/SIGTERM stop the server gracefully and then the bot
+    stop_c := make(chan os.Signal, 1)
+    signal.Notify(stop_c, os.Interrupt, syscall.SIGTERM)
+    shutting_down := false
+    // Fires when the server took too long to stop
+    var shutdown_c <-chan time.Time
+
+    // Don't flood the admins with the same error over and over
+    errors_throttle := make_throttle(ERROR_THROTTLE)
 
     // Main loop
     // The .In() channels could've been replaced with calling methods but
@@ -57,19 +148,50 @@ func main() {
         }
 
         select {
+        case sig := <-stop_c:
+            if shutting_down {
+                log.Println("Got", sig, "again, killing the server")
+                srv.Kill()
+                break
+            }
+
+            log.Println("Got", sig, "shutting down...")
+            shutting_down = true
+            if !srv.IsRunning() {
+                // Nothing to wait for
+                thebot.In() <- bot.InputEventSendMessage{
+                    Message: "Bot shutting down",
+                }
+                stopping = true
+                thebot.Stop()
+                break
+            }
+
+            thebot.In() <- bot.InputEventSendMessage{
+                Message: "Bot is shutting down, stopping the server...",
+            }
+            srv.In() <- server.InputEventKillServer{}
+
+            shutdown_c = time.After(config.ShutdownWait())
+        case <-shutdown_c:
+            log.Println("Server did not stop in time, killing it")
+            thebot.In() <- bot.InputEventSendMessage{
+                Message: "Server did not stop in time, killing it",
+            }
+            srv.Kill()
+        case <-reload_c:
+            msg := reload()
+            log.Println(msg)
+            thebot.In() <- bot.InputEventSendMessage{ Message: msg }
         case srv_out := <-srv.Out():
             switch event := srv_out.(type) {
             case server.OutputEventExit:
                 log.Println("Server exited, got code", event.ExitCode)
-                thebot.In() <- bot.InputEventSendMessage{
-                    Message: fmt.Sprintf(
-                        "Server shut down with exit code %d",
-                        event.ExitCode,
-                    ),
-                }
+                msg, _ := tg_message(&srv, event)
+                thebot.In() <- bot.InputEventSendMessage{ Text: msg }
 
                 stopping = true
-                if srv.TryRestart {
+                if srv.TryRestart && !shutting_down {
                     thebot.In() <- bot.InputEventSendMessage{
                         Message: "Restarting...",
                     }
@@ -85,49 +207,60 @@ func main() {
                 }
 
                 if stopping {
+                    if shutting_down {
+                        thebot.In() <- bot.InputEventSendMessage{
+                            Message: "Bot shutting down",
+                        }
+                    }
                     thebot.Stop()
                 }
-            case server.OutputEventPlayerJoined:
+            case server.OutputEventStopEscalation,
+                 server.OutputEventServerHung,
+                 server.OutputEventServerRecovered:
+                msg, _ := tg_message(&srv, event)
+                log.Println(msg)
                 thebot.In() <- bot.InputEventSendMessage{
-                    Message: fmt.Sprintf(
-                        "%s joined the game",
-                        tg_msg_name(event.Username),
-                    ),
+                    Text:  msg,
+                    Admin: true,
                 }
-            case server.OutputEventPlayerLeft:
-                thebot.In() <- bot.InputEventSendMessage{
-                    Message: fmt.Sprintf(
-                        "%s left the game",
-                        tg_msg_name(event.Username),
-                    ),
+            case server.OutputEventPlayerJoined,
+                 server.OutputEventPlayerLeft,
+                 server.OutputEventPlayerAchievement,
+                 server.OutputEventServerLoaded,
+                 server.OutputEventListPlayers,
+                 server.OutputEventWhisperFailed,
+                 server.OutputEventPlayerDeath:
+                msg, _ := tg_message(&srv, event)
+                thebot.In() <- bot.InputEventSendMessage{ Text: msg }
+            case server.OutputEventLog:
+                if event.Stderr {
+                    log.Print("[stderr] ", event.Message)
+                } else {
+                    log.Println(event.Message)
                 }
-            case server.OutputEventPlayerAchievement:
-                thebot.In() <- bot.InputEventSendMessage{
-                    Message: fmt.Sprintf(
-                        "%s has achieved: %s",
-                        tg_msg_name(event.Username),
-                        event.Achievement,
-                    ),
+            case server.OutputEventCrashReport:
+                msg, _ := tg_message(&srv, event)
+                log.Println(msg)
+                thebot.In() <- bot.InputEventSendDocument{
+                    Path:    event.Path,
+                    Caption: msg.String(),
+                    Admin:   true,
                 }
-            case server.OutputEventServerLoaded:
-                thebot.In() <- bot.InputEventSendMessage{
-                    Message: "Server successfully started",
+            case server.OutputEventServerError:
+                if !config.ForwardErrors || !errors_throttle.Allow(event.Message) {
+                    break
                 }
-            case server.OutputEventListPlayers:
-                msg := fmt.Sprintf("%d players:\n", len(event.PlayersOnline))
-                for _, player := range event.PlayersOnline {
-                    msg += fmt.Sprintf("* %s\n", tg_msg_name(player))
+                msg, _ := tg_message(&srv, event)
+                thebot.In() <- bot.InputEventSendMessage{
+                    Text:  msg,
+                    Admin: true,
                 }
-                thebot.In() <- bot.InputEventSendMessage{ Message: msg }
-            case server.OutputEventLog:
-                log.Println(event.Message)
             case server.OutputEventMessage:
+                msg, _ := tg_message(&srv, event)
                 thebot.In() <- bot.InputEventSendMessage{
-                     Message: fmt.Sprintf(
-                         "%s: %s",
-                         tg_msg_name(event.Username),
-                         event.Message,
-                     ),
+                    Text:     msg,
+                    Username: event.Username,
+                    Mentions: mc_mentions(&srv, event.Message),
                 }
 
                 if event.Tellraw {
@@ -139,14 +272,6 @@ func main() {
                         Message:  event.Message,
                     }
                 }
-            case server.OutputEventPlayerDeath:
-                thebot.In() <- bot.InputEventSendMessage{
-                    Message: fmt.Sprintf(
-                        "%s: %s\nYikes...\n",
-                        tg_msg_name(event.Username),
-                        event.Message,
-                    ),
-                }
             case server.OutputEventError:
                 log.Println("Server error:", event.Error)
                 if event.Error.Error() == "User error" {
@@ -160,15 +285,27 @@ func main() {
         case bot_out := <-thebot.Out():
             switch event := bot_out.(type) {
             case bot.OutputEventMessage:
-                srv.In() <- server.InputEventChat{
+                chat := server.InputEventChat{
                     Telegram: true,
                     Username: event.Username,
                     Message:  event.Message,
+                    Styles:   mc_styles(event.Message, event.Entities),
+                    Media:    event.Media,
+                    Link:     event.Link,
+                    To:       event.Whisper,
+                }
+                if event.Reply != nil {
+                    chat.ReplyTo = event.Reply.Username
+                    chat.ReplyText = event.Reply.Text
                 }
+                srv.In() <- chat
             case bot.OutputEventEditMessage:
                 srv.In() <- server.InputEventEditChat{
                     Username: event.Username,
                     Message:  event.Message,
+                    Styles:   mc_styles(event.Message, event.Entities),
+                    Original: event.Original,
+                    To:       event.Whisper,
                 }
             case bot.OutputEventCommand:
                 srv.In() <- server.InputEventCommand{
@@ -178,6 +315,10 @@ func main() {
                 srv.In() <- server.InputEventListPlayers{}
             case bot.OutputEventKillServer:
                 srv.In() <- server.InputEventKillServer{}
+            case bot.OutputEventReload:
+                msg := reload()
+                log.Println(msg)
+                thebot.In() <- bot.InputEventSendMessage{ Message: msg }
             case bot.OutputEventBindUser:
                 srv.In() <- server.InputEventBindRename{
                     Username:    event.TelegramName,
@@ -194,4 +335,6 @@ func main() {
             }
         }
     }
-} // <-- main()
+
+    return 0
+} // <-- cmd_run(args)
@@ -0,0 +1,23 @@
+// mentions.go
+// Mentions of Telegram users in Minecraft chat
+package main
+
+import (
+    "github.com/gregthemadmonk/mctg-server-bot/server"
+)
+
+// Words of a Minecraft chat message that mention Telegram users: `@name`,
+// where the name is a player or a Telegram username bound with /iamthe.
+// Maps the words to the Telegram usernames
+func mc_mentions(srv *server.Handle, text string) map[string]string {
+    var ret map[string]string
+    for _, word := range server.MentionRegexp.FindAllString(text, -1) {
+        if tg_name, found := srv.TelegramUser(word[1:]); found {
+            if ret == nil {
+                ret = make(map[string]string)
+            }
+            ret[word] = tg_name
+        }
+    }
+    return ret
+} // <-- mc_mentions(srv, text)
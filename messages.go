@@ -0,0 +1,142 @@
+// messages.go
+// Telegram messages for the server events
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "strings"
+
+    "github.com/gregthemadmonk/mctg-server-bot/bot"
+    "github.com/gregthemadmonk/mctg-server-bot/server"
+)
+
+// Stack trace lines to include in the error messages
+const ERROR_TRACE_LINES = 8
+
+// Player name as shown in Telegram: with the bound Telegram user, if any
+func tg_msg_name(srv *server.Handle, usr string) string {
+    tg_name := srv.ReverseRename(usr)
+    if tg_name == usr {
+        return usr
+    }
+    return fmt.Sprintf("%s (%s)", usr, tg_name)
+} // <-- tg_msg_name(srv, usr)
+
+// Telegram message announcing the server event. Player names are bold and
+// the server's own events are italic. Returns false if the event is not
+// relayed to Telegram
+func tg_message(srv *server.Handle, srv_out any) (bot.Text, bool) {
+    system := func(format string, args ...any) bot.Text {
+        return bot.Text{ bot.Italic(fmt.Sprintf(format, args...)) }
+    } // <-- system(format, args...)
+    player := func(usr string, format string, args ...any) bot.Text {
+        return bot.Text{
+            bot.Bold(tg_msg_name(srv, usr)),
+            bot.Italic(fmt.Sprintf(format, args...)),
+        }
+    } // <-- player(usr, format, args...)
+
+    switch event := srv_out.(type) {
+    case server.OutputEventExit:
+        return system("Server shut down with exit code %d", event.ExitCode), true
+    case server.OutputEventStopEscalation:
+        switch event.State {
+        case server.HS_TERMINATING:
+            return system(
+                "Server did not stop in %s, sending SIGTERM", event.After,
+            ), true
+        case server.HS_KILLING:
+            return system(
+                "Server is still running %s after SIGTERM, killing it",
+                event.After,
+            ), true
+        }
+    case server.OutputEventServerHung:
+        msg := fmt.Sprintf(
+            "Server is not responding: %d console probes in a row went unanswered",
+            event.Missed,
+        )
+        if event.Restart {
+            msg += ". Restarting it..."
+        }
+        return system("%s", msg), true
+    case server.OutputEventServerRecovered:
+        return system("Server is responding again"), true
+    case server.OutputEventServerError:
+        head := "Server " + event.Level
+        if event.Stderr {
+            head += " (stderr)"
+        } else if len(event.Logger) != 0 {
+            head += fmt.Sprintf(" [%s/%s]", event.Thread, event.Logger)
+        }
+        msg := bot.Text{ bot.Bold(head), bot.Plain(": " + event.Message) }
+        // The top of the stack trace is usually enough to tell what happened
+        trace := event.Trace
+        if len(trace) > ERROR_TRACE_LINES {
+            trace = append(
+                trace[:ERROR_TRACE_LINES:ERROR_TRACE_LINES],
+                fmt.Sprintf("... %d more lines", len(event.Trace) - ERROR_TRACE_LINES),
+            )
+        }
+        if len(trace) != 0 {
+            msg = append(
+                msg, bot.Plain("\n"), bot.Pre(strings.Join(trace, "\n")),
+            )
+        }
+        return msg, true
+    case server.OutputEventCrashReport:
+        // Sent as a document caption, which is not formatted
+        msg := "Server crashed: " + filepath.Base(event.Path)
+        if len(event.Description) != 0 {
+            msg += "\n" + event.Description
+        }
+        if len(event.Exception) != 0 {
+            msg += "\n" + event.Exception
+        }
+        if len(event.SuspectedMod) != 0 {
+            msg += "\nSuspected: " + event.SuspectedMod
+        }
+        return bot.PlainText(msg), true
+    case server.OutputEventPlayerJoined:
+        return player(event.Username, " joined the game"), true
+    case server.OutputEventPlayerLeft:
+        return player(event.Username, " left the game"), true
+    case server.OutputEventPlayerAchievement:
+        return player(
+            event.Username, " has achieved: %s", event.Achievement,
+        ), true
+    case server.OutputEventServerLoaded:
+        return system("Server successfully started"), true
+    case server.OutputEventListPlayers:
+        msg := bot.Text{
+            bot.Plain(fmt.Sprintf("%d players:\n", len(event.PlayersOnline))),
+        }
+        for _, player := range event.PlayersOnline {
+            msg = append(
+                msg,
+                bot.Plain("* "),
+                bot.Bold(tg_msg_name(srv, player)),
+                bot.Plain("\n"),
+            )
+        }
+        return msg, true
+    case server.OutputEventWhisperFailed:
+        return player(
+            event.Player, " is not online, the message was not delivered",
+        ), true
+    case server.OutputEventMessage:
+        return bot.Text{
+            bot.Bold(tg_msg_name(srv, event.Username)),
+            bot.Plain(": " + event.Message),
+        }, true
+    case server.OutputEventPlayerDeath:
+        return bot.Text{
+            bot.Bold(tg_msg_name(srv, event.Username)),
+            bot.Plain(": "),
+            bot.Italic(event.Message),
+            bot.Plain("\nYikes...\n"),
+        }, true
+    }
+    return nil, false
+} // <-- tg_message(srv, srv_out)
@@ -0,0 +1,41 @@
+// reload.go
+// Applying a re-read config to the running bot
+package main
+
+import (
+    "reflect"
+)
+
+// Names of the changed fields that are marked with `reload:"restart"`, i.e.
+// can't be applied to the running bot or server
+func restart_fields(prefix string, old reflect.Value, new reflect.Value) []string {
+    var ret []string
+
+    typ := old.Type()
+    for i := range typ.NumField() {
+        field := typ.Field(i)
+        name := json_tag(field)
+        if len(name) == 0 {
+            continue
+        }
+        if len(prefix) != 0 {
+            name = prefix + "." + name
+        }
+
+        if field.Type.Kind() == reflect.Struct {
+            ret = append(
+                ret, restart_fields(name, old.Field(i), new.Field(i))...,
+            )
+            continue
+        }
+
+        if field.Tag.Get("reload") != "restart" {
+            continue
+        }
+        if !reflect.DeepEqual(old.Field(i).Interface(), new.Field(i).Interface()) {
+            ret = append(ret, name)
+        }
+    }
+
+    return ret
+} // <-- restart_fields(prefix, old, new)
@@ -0,0 +1,99 @@
+// replay.go
+// Dry-run the console parser over an existing server log
+package main
+
+import (
+    "bufio"
+    "compress/gzip"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "github.com/gregthemadmonk/mctg-server-bot/server"
+)
+
+// replay [-v] <latest.log|archive.log.gz>...
+// Prints the events parsed from every line and the Telegram messages the
+// bot would send for them. Does not start the server or contact Telegram
+func cmd_replay(args []string) int {
+    flags := flag.NewFlagSet("replay", flag.ExitOnError)
+    verbose := flags.Bool("v", false, "Print lines that produce no events too")
+    flags.Usage = func() {
+        fmt.Fprintln(
+            flags.Output(),
+            "Usage: replay [-v] <latest.log|archive.log.gz>...",
+        )
+        flags.PrintDefaults()
+    }
+    flags.Parse(args)
+
+    if flags.NArg() == 0 {
+        flags.Usage()
+        return 2
+    }
+
+    srv := server.MakeHandle(server.Config{})
+    for _, path := range flags.Args() {
+        if err := replay_file(&srv, path, *verbose); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            return 1
+        }
+    }
+    return 0
+} // <-- cmd_replay(args)
+
+func replay_file(srv *server.Handle, path string, verbose bool) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    var reader io.Reader = bufio.NewReader(file)
+    // Detect gzip by the magic number rather than the file extension
+    if magic, _ := reader.(*bufio.Reader).Peek(2); len(magic) == 2 &&
+        magic[0] == 0x1f && magic[1] == 0x8b {
+        gz, gz_err := gzip.NewReader(reader)
+        if gz_err != nil {
+            return fmt.Errorf("%s: %w", path, gz_err)
+        }
+        defer gz.Close()
+        reader = gz
+    }
+
+    line_no := 0
+    handle := func(record string, events []any) {
+        start := line_no + 1
+        line_no += strings.Count(record, "\n")
+
+        var out []string
+        for _, event := range events {
+            if _, is_log := event.(server.OutputEventLog); is_log && !verbose {
+                continue
+            }
+            out = append(out, fmt.Sprintf("    %T%+v", event, event))
+            if msg, ok := tg_message(srv, event); ok {
+                out = append(
+                    out,
+                    "    -> Telegram: " + strings.ReplaceAll(
+                        strings.TrimRight(msg.String(), "\n"), "\n", "\n                 ",
+                    ),
+                )
+            }
+        }
+
+        if len(out) != 0 || verbose {
+            fmt.Printf("%s:%d: %s", path, start, record)
+            for _, l := range out {
+                fmt.Println(l)
+            }
+        }
+    } // <-- handle(record, events)
+
+    if err := srv.Replay(reader, handle); err != nil {
+        return fmt.Errorf("%s: %w", path, err)
+    }
+    return nil
+} // <-- replay_file(srv, path, verbose)
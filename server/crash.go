@@ -0,0 +1,129 @@
+// crash.go
+// Find and summarize the crash reports left by the server
+package server
+
+import (
+    "bufio"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Find the crash reports written after `since`: Minecraft's
+// crash-reports/crash-*.txt and the JVM's hs_err_pid*.log
+func find_crash_reports(workdir string, since time.Time) []string {
+    if len(workdir) == 0 {
+        workdir = "."
+    }
+
+    var ret []string
+    for _, pattern := range []string{
+        filepath.Join(workdir, "crash-reports", "crash-*.txt"),
+        filepath.Join(workdir, "hs_err_pid*.log"),
+    } {
+        matches, _ := filepath.Glob(pattern)
+        for _, path := range matches {
+            info, err := os.Stat(path)
+            // Some filesystems only store whole seconds
+            if err == nil && !info.ModTime().Before(since.Truncate(time.Second)) {
+                ret = append(ret, path)
+            }
+        }
+    }
+
+    sort.Strings(ret)
+    return ret
+} // <-- find_crash_reports(workdir, since)
+
+// Read the crash report and pick out the interesting lines
+func summarize_crash_report(path string) (OutputEventCrashReport, error) {
+    ret := OutputEventCrashReport{ Path: path }
+
+    file, err := os.Open(path)
+    if err != nil {
+        return ret, err
+    }
+    defer file.Close()
+
+    var lines []string
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(nil, 1 << 20)
+    for scanner.Scan() {
+        lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+    }
+    if err := scanner.Err(); err != nil {
+        return ret, err
+    }
+
+    if strings.HasPrefix(filepath.Base(path), "hs_err_pid") {
+        summarize_hs_err(&ret, lines)
+    } else {
+        summarize_minecraft_crash(&ret, lines)
+    }
+    return ret, nil
+} // <-- summarize_crash_report(path)
+
+// ---- Minecraft Crash Report ----
+// ...
+// Description: Exception in server tick loop
+//
+// java.lang.NullPointerException: ...
+// ...
+// Suspected Mod:
+//     Some Mod (somemod), Version: 1.0
+func summarize_minecraft_crash(report *OutputEventCrashReport, lines []string) {
+    for i, line := range lines {
+        switch {
+        case len(report.Description) == 0 && strings.HasPrefix(line, "Description:"):
+            report.Description = strings.TrimSpace(line[len("Description:"):])
+            // The exception follows the description after an empty line
+            for _, next := range lines[i + 1:] {
+                if next = strings.TrimSpace(next); len(next) != 0 {
+                    report.Exception = next
+                    break
+                }
+            }
+        case len(report.SuspectedMod) == 0 && strings.HasPrefix(strings.TrimSpace(line), "Suspected Mod"):
+            _, value, _ := strings.Cut(line, ":")
+            value = strings.TrimSpace(value)
+            if len(value) == 0 && i + 1 < len(lines) {
+                value = strings.TrimSpace(lines[i + 1])
+            }
+            if value != "NONE" && value != "UNKNOWN" {
+                report.SuspectedMod = value
+            }
+        }
+    }
+} // <-- summarize_minecraft_crash(report, lines)
+
+// #
+// # A fatal error has been detected by the Java Runtime Environment:
+// #
+// #  SIGSEGV (0xb) at pc=0x00007f..., pid=1234, tid=1235
+// ...
+// # Problematic frame:
+// # C  [libc.so.6+0x1234]
+func summarize_hs_err(report *OutputEventCrashReport, lines []string) {
+    comment := func(line string) string {
+        return strings.TrimSpace(strings.TrimPrefix(line, "#"))
+    } // <-- comment(line)
+
+    for i, line := range lines {
+        if !strings.HasPrefix(line, "#") {
+            break // End of the header
+        }
+
+        text := comment(line)
+        switch {
+        case len(text) == 0:
+        case len(report.Description) == 0:
+            report.Description = strings.TrimSuffix(text, ":")
+        case len(report.Exception) == 0:
+            report.Exception = text
+        case text == "Problematic frame:" && i + 1 < len(lines):
+            report.SuspectedMod = comment(lines[i + 1])
+        }
+    }
+} // <-- summarize_hs_err(report, lines)
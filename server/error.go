@@ -5,6 +5,7 @@ const (
     ERR_ETYPE   = iota
     ERR_TRAWJS  = iota
     ERR_USER    = iota
+    ERR_CMDLINE = iota
 )
 
 type Error struct {
@@ -21,6 +22,8 @@ func (self *Error) Error() string {
         return "Cannot serialize /tellraw message argument"
     case ERR_USER:
         return "User error"
+    case ERR_CMDLINE:
+        return "Server command line is empty"
     default:
         return "Unknown server error"
     }
@@ -1,7 +1,13 @@
 package server
 
+import (
+    "time"
+)
+
 type OutputEventLog struct {
     Message string
+    // The line comes from the server's stderr
+    Stderr  bool
 } // <-- struct OutputEventLog
 
 type OutputEventMessage struct {
@@ -34,6 +40,11 @@ type OutputEventListPlayers struct {
     PlayersOnline []string
 } // <-- struct OutputEventListPlayers
 
+// A private message could not be delivered: the player is not online
+type OutputEventWhisperFailed struct {
+    Player string
+} // <-- struct OutputEventWhisperFailed
+
 type OutputEventListTeams struct {
     Teams []string
 } // <-- struct OutputEventListTeams
@@ -42,10 +53,57 @@ type OutputEventTeamMapping struct {
     Mapping TeamMapping
 } // <-- struct OutputEventTeamMapping
 
+// The server has logged an error or an uncaught exception
+type OutputEventServerError struct {
+    // Written to stderr rather than the log
+    Stderr  bool
+    // Log level: ERROR or FATAL
+    Level   string
+    // Logging thread and logger, e.g. "Server thread", "minecraft/MinecraftServer"
+    Thread  string
+    Logger  string
+    // The first line of the record
+    Message string
+    // The rest of the record (stack trace)
+    Trace   []string
+} // <-- struct OutputEventServerError
+
 type OutputEventError struct {
     Error error
 } // <-- struct OutputEventError
 
+// The server did not exit in time after being asked to stop, the next step
+// is taken
+type OutputEventStopEscalation struct {
+    // HS_TERMINATING or HS_KILLING
+    State uint
+    // Time the server was given to exit after the previous step
+    After time.Duration
+} // <-- struct OutputEventStopEscalation
+
+// The server does not answer the watchdog's probes
+type OutputEventServerHung struct {
+    // Probes missed in a row
+    Missed  uint
+    // The server is being killed to be restarted
+    Restart bool
+} // <-- struct OutputEventServerHung
+
+// The server answers the watchdog's probes again
+type OutputEventServerRecovered struct {}
+
+// The server has crashed and left a crash report
+type OutputEventCrashReport struct {
+    // Crash report file
+    Path         string
+    // What happened, e.g. "Exception in server tick loop"
+    Description  string
+    // The exception or the signal that killed the JVM
+    Exception    string
+    // The mod the crash report blames (the problematic frame for JVM crashes)
+    SuspectedMod string
+} // <-- struct OutputEventCrashReport
+
 type OutputEventExit struct {
     // -2 if can't get ExitCode()
     ExitCode int
@@ -56,6 +114,19 @@ type input_event_terminate struct {}
 
 type input_event_fetch_teams struct {}
 
+// Watchdog's liveness probe
+type input_event_probe struct {}
+
+// The server has listed the players online
+type input_event_player_list struct {
+    Players []string
+} // <-- struct input_event_player_list
+
+// The server has listed its teams
+type input_event_team_list struct {
+    Teams []string
+} // <-- struct input_event_team_list
+
 type input_event_req_team struct {
     Team string
 } // <-- struct input_event_req_team
@@ -69,12 +140,32 @@ type InputEventChat struct {
     Telegram bool
     Username string
     Message  string
+    // Formatting of the message
+    Styles   []Style
+    // Description of the attached media (photo, sticker...), shown as a
+    // placeholder before the message
+    Media    string
+    // Link the placeholder opens, if any
+    Link     string
+    // The message is a reply to this text...
+    ReplyText string
+    // ...written by this Telegram user (empty if the author is unknown or
+    // is a part of the text)
+    ReplyTo   string
+    // Player to show the message to privately. Empty for everyone
+    To        string
 } // <-- struct InputEventChat
 
 type InputEventEditChat struct {
     Username string
     Message  string
-} // <-- struct InputEventChat
+    // Formatting of the message
+    Styles   []Style
+    // Text before the edit, empty if unknown
+    Original string
+    // The original message was whispered to this player
+    To       string
+} // <-- struct InputEventEditChat
 
 type InputEventCommand struct {
     Command string
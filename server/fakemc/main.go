@@ -0,0 +1,463 @@
+// main.go
+// Fake Minecraft server for testing the server supervisor without a real
+// server jar. Put it into the server's `cmdline`, e.g.
+//     "cmdline": [ "go", "run", "./server/fakemc", "-mod" ]
+//
+// Prints NeoForge-like console lines and understands a few real console
+// commands (list, say, team, tellraw, playsound, title, stop). Commands
+// starting with `fake` simulate what players do on the server:
+//     fake join <player>
+//     fake leave <player>
+//     fake chat <player> <text>
+//     fake death <player> <message>
+//     fake advancement <player> <title>
+//     fake log <level> <text>
+//     fake stderr <text>
+//     fake exception <message>
+//     fake crash <code>
+//     fake exit <code>
+//     fake hang
+// `sleep <duration>` pauses command processing (useful in scripts)
+//
+// With `-tellraw-log <file>`, every /tellraw command is appended to the file
+// as "<target> <json>", so tests can check what the players were shown
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+)
+
+// Fake server state
+type server struct {
+    mu       sync.Mutex
+    out      io.Writer
+    // The mod is installed: chat and deaths are logged with mod markers
+    mod      bool
+    // Exit code after /stop
+    code     int
+    players  []string
+    // Team name -> members
+    teams    map[string][]string
+    // The server has stopped responding to commands
+    hung     bool
+    // Where to record the /tellraw commands, may be nil
+    tellraw  io.Writer
+} // <-- struct server
+
+// Print a console line in the server log format
+func (self *server) log(thread string, level string, logger string, msg string) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    fmt.Fprintf(
+        self.out,
+        "[%s] [%s/%s] [%s]: %s\n",
+        time.Now().Format("15:04:05"),
+        thread,
+        level,
+        logger,
+        msg,
+    )
+} // <-- server::log(thread, level, logger, msg)
+
+// Print a line from the server thread
+func (self *server) info(msg string) {
+    self.log("Server thread", "INFO", "minecraft/MinecraftServer", msg)
+} // <-- server::info(msg)
+
+// Print a line from the mod
+func (self *server) mod_info(msg string) {
+    self.log("Server thread", "INFO", "co.gr.mc.MCTGMod/", msg)
+} // <-- server::mod_info(msg)
+
+func (self *server) startup(delay time.Duration) {
+    self.log("main", "INFO", "minecraft/Main", "Starting fake Minecraft server")
+    self.log(
+        "Server thread", "INFO", "minecraft/DedicatedServer",
+        "Starting minecraft server version 1.21.1",
+    )
+    self.log(
+        "Server thread", "INFO", "minecraft/DedicatedServer",
+        "Loading properties",
+    )
+    self.log(
+        "Server thread", "INFO", "minecraft/DedicatedServer",
+        "Starting Minecraft server on *:25565",
+    )
+    self.info("Preparing level \"world\"")
+    time.Sleep(delay)
+    self.log(
+        "Server thread", "INFO", "minecraft/DedicatedServer",
+        fmt.Sprintf(
+            "Done (%.3fs)! For help, type \"help\"",
+            delay.Seconds() + 1,
+        ),
+    )
+} // <-- server::startup(delay)
+
+func (self *server) shutdown() {
+    self.info("Stopping the server")
+    self.info("Stopping server")
+    self.info("Saving players")
+    for _, player := range self.players {
+        self.info(player + " left the game")
+    }
+    self.info("Saving worlds")
+    self.info("ThreadedAnvilChunkStorage: All dimensions are saved")
+    os.Exit(self.code)
+} // <-- server::shutdown()
+
+// Handle a single command. Returns false if the command is unknown
+func (self *server) command(line string) bool {
+    line = strings.TrimPrefix(strings.TrimSpace(line), "/")
+    if len(line) == 0 {
+        return true
+    }
+    argv := strings.Fields(line)
+    // The rest of the line after `n` words
+    rest := func(n int) string {
+        text := line
+        for range n {
+            text = strings.TrimLeft(text, " ")
+            if i := strings.IndexByte(text, ' '); i >= 0 {
+                text = text[i:]
+            } else {
+                return ""
+            }
+        }
+        return strings.TrimLeft(text, " ")
+    } // <-- rest(n)
+
+    switch argv[0] {
+    case "sleep":
+        if len(argv) == 2 {
+            if d, err := time.ParseDuration(argv[1]); err == nil {
+                time.Sleep(d)
+                return true
+            }
+        }
+        return false
+    case "fake":
+        return self.fake(argv[1:], rest)
+    }
+
+    if self.hung {
+        return true // Nobody is reading the console
+    }
+
+    switch argv[0] {
+    case "stop":
+        self.shutdown()
+    case "list":
+        self.info(
+            fmt.Sprintf(
+                "There are %d of a max of 20 players online: %s",
+                len(self.players),
+                strings.Join(self.players, ", "),
+            ),
+        )
+    case "say":
+        self.info("[Server] " + rest(1))
+    case "tellraw":
+        // Not logged by the real server either
+        if self.tellraw != nil && len(argv) > 1 {
+            fmt.Fprintf(self.tellraw, "%s %s\n", argv[1], rest(2))
+        }
+    case "playsound":
+        if len(argv) < 4 {
+            return false
+        }
+        self.info(fmt.Sprintf("Played sound %s to %s", argv[1], argv[3]))
+    case "title":
+        if len(argv) < 3 {
+            return false
+        }
+        kind := argv[2]
+        if kind == "title" {
+            kind = "new"
+        }
+        self.info(fmt.Sprintf("Showing %s title for %s", kind, argv[1]))
+    case "team":
+        return self.team(argv[1:])
+    default:
+        self.info(
+            "Unknown or incomplete command, see below for error",
+        )
+        return false
+    }
+    return true
+} // <-- server::command(line)
+
+// Simulate player actions
+func (self *server) fake(argv []string, rest func(int) string) bool {
+    if len(argv) == 0 {
+        return false
+    }
+
+    switch {
+    case argv[0] == "join" && len(argv) == 2:
+        if !slices.Contains(self.players, argv[1]) {
+            self.players = append(self.players, argv[1])
+        }
+        self.info(argv[1] + " joined the game")
+    case argv[0] == "leave" && len(argv) == 2:
+        if i := slices.Index(self.players, argv[1]); i >= 0 {
+            self.players = slices.Delete(self.players, i, i + 1)
+        }
+        self.info(argv[1] + " left the game")
+    case argv[0] == "chat" && len(argv) >= 3:
+        if self.mod {
+            self.mod_info("\x1bCHAT\x1b" + argv[1] + "\x1b" + rest(3))
+        } else {
+            self.info("<" + argv[1] + "> " + rest(3))
+        }
+    case argv[0] == "death" && len(argv) >= 3:
+        if self.mod {
+            self.mod_info("\x1bDEATH\x1b" + argv[1] + "\x1b" + rest(3))
+        }
+        self.info(rest(3))
+    case argv[0] == "advancement" && len(argv) >= 3:
+        self.info(
+            fmt.Sprintf("%s has made the advancement [%s]", argv[1], rest(3)),
+        )
+    case argv[0] == "log" && len(argv) >= 3:
+        self.log(
+            "Server thread", strings.ToUpper(argv[1]),
+            "minecraft/MinecraftServer", rest(3),
+        )
+    case argv[0] == "stderr" && len(argv) >= 2:
+        self.mu.Lock()
+        fmt.Fprintln(os.Stderr, rest(2))
+        self.mu.Unlock()
+    case argv[0] == "exception" && len(argv) >= 2:
+        // What an uncaught exception looks like before log4j is set up
+        self.mu.Lock()
+        fmt.Fprintf(
+            os.Stderr,
+            "Exception in thread \"main\" java.lang.IllegalStateException: %s\n" +
+            "\tat net.minecraft.server.Main.main(Main.java:42)\n" +
+            "\tat java.base/jdk.internal.reflect.DirectMethodHandleAccessor.invoke(DirectMethodHandleAccessor.java:103)\n" +
+            "Caused by: java.lang.NullPointerException\n" +
+            "\tat net.minecraft.server.Bootstrap.bootStrap(Bootstrap.java:56)\n" +
+            "\t... 2 more\n",
+            rest(2),
+        )
+        self.mu.Unlock()
+    case argv[0] == "crash" && len(argv) == 2:
+        code, err := strconv.Atoi(argv[1])
+        if err != nil {
+            return false
+        }
+        self.crash(code)
+    case argv[0] == "exit" && len(argv) == 2:
+        code, err := strconv.Atoi(argv[1])
+        if err != nil {
+            return false
+        }
+        os.Exit(code)
+    case argv[0] == "hang" && len(argv) == 1:
+        // A deadlocked server often doesn't even react to SIGTERM: the
+        // shutdown hooks wait for the stuck thread
+        self.hung = true
+        signal.Ignore(syscall.SIGTERM)
+    default:
+        return false
+    }
+    return true
+} // <-- server::fake(argv, rest)
+
+// Write a crash report like the server does and exit with the code
+func (self *server) crash(code int) {
+    now := time.Now()
+    path := filepath.Join(
+        "crash-reports",
+        "crash-" + now.Format("2006-01-02_15.04.05") + "-server.txt",
+    )
+    report := "---- Minecraft Crash Report ----\n" +
+        "// Don't be sad, have a hug! <3\n" +
+        "\n" +
+        "Time: " + now.Format("2006-01-02 15:04:05") + "\n" +
+        "Description: Exception in server tick loop\n" +
+        "\n" +
+        "java.lang.NullPointerException: Cannot invoke \"Object.toString()\" because \"value\" is null\n" +
+        "\tat TRANSFORMER/fakemod@1.0.0/com.example.fakemod.Ticker.tick(Ticker.java:42) ~[fakemod-1.0.0.jar%23123!/:?] {re:classloading}\n" +
+        "\tat TRANSFORMER/minecraft@1.21.1/net.minecraft.server.MinecraftServer.tickServer(MinecraftServer.java:1000) ~[server-1.21.1.jar%23456!/:?] {re:classloading}\n" +
+        "\n" +
+        "-- Head --\n" +
+        "Thread: Server thread\n" +
+        "Suspected Mod: \n" +
+        "\tFake Mod (fakemod), Version: 1.0.0\n" +
+        "\t\tat TRANSFORMER/fakemod@1.0.0/com.example.fakemod.Ticker.tick(Ticker.java:42)\n"
+
+    self.log(
+        "Server thread", "ERROR", "minecraft/MinecraftServer",
+        "Encountered an unexpected exception",
+    )
+    if err := os.MkdirAll("crash-reports", 0755); err == nil {
+        if err := os.WriteFile(path, []byte(report), 0644); err == nil {
+            self.log(
+                "Server thread", "ERROR", "minecraft/MinecraftServer",
+                "This crash report has been saved to: " + path,
+            )
+        }
+    }
+    os.Exit(code)
+} // <-- server::crash(code)
+
+// Handle the /team command
+func (self *server) team(argv []string) bool {
+    bracket := func(names []string) string {
+        ret := make([]string, len(names))
+        for i, name := range names {
+            ret[i] = "[" + name + "]"
+        }
+        return strings.Join(ret, ", ")
+    } // <-- bracket(names)
+
+    switch {
+    case len(argv) == 1 && argv[0] == "list":
+        if len(self.teams) == 0 {
+            self.info("There are no teams")
+            return true
+        }
+        var names []string
+        for name := range self.teams {
+            names = append(names, name)
+        }
+        slices.Sort(names)
+        self.info(
+            fmt.Sprintf(
+                "There are %d team(s): %s", len(names), bracket(names),
+            ),
+        )
+    case len(argv) == 2 && argv[0] == "list":
+        members, found := self.teams[argv[1]]
+        if !found {
+            self.info(fmt.Sprintf("Unknown team '%s'", argv[1]))
+        } else if len(members) == 0 {
+            self.info(fmt.Sprintf("There are no members on team [%s]", argv[1]))
+        } else {
+            self.info(
+                fmt.Sprintf(
+                    "Team [%s] has %d member(s): %s",
+                    argv[1],
+                    len(members),
+                    strings.Join(members, ", "),
+                ),
+            )
+        }
+    case len(argv) == 2 && argv[0] == "add":
+        if _, found := self.teams[argv[1]]; found {
+            self.info("A team already exists by that name")
+            return true
+        }
+        self.teams[argv[1]] = nil
+        self.info(fmt.Sprintf("Created team [%s]", argv[1]))
+    case len(argv) == 2 && argv[0] == "remove":
+        if _, found := self.teams[argv[1]]; !found {
+            self.info(fmt.Sprintf("Unknown team '%s'", argv[1]))
+            return true
+        }
+        delete(self.teams, argv[1])
+        self.info(fmt.Sprintf("Removed team [%s]", argv[1]))
+    case len(argv) == 3 && argv[0] == "join":
+        if _, found := self.teams[argv[1]]; !found {
+            self.info(fmt.Sprintf("Unknown team '%s'", argv[1]))
+            return true
+        }
+        // A player can only be in one team
+        for name, members := range self.teams {
+            if i := slices.Index(members, argv[2]); i >= 0 {
+                self.teams[name] = slices.Delete(members, i, i + 1)
+            }
+        }
+        self.teams[argv[1]] = append(self.teams[argv[1]], argv[2])
+        self.info(
+            fmt.Sprintf("Added %s to team [%s]", argv[2], argv[1]),
+        )
+    default:
+        return false
+    }
+    return true
+} // <-- server::team(argv)
+
+// Run the commands from the file, one per line
+func (self *server) script(path string) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if len(line) == 0 || line[0] == '#' {
+            continue
+        }
+        if !self.command(line) {
+            return fmt.Errorf("%s: bad command: %s", path, line)
+        }
+    }
+    return scanner.Err()
+} // <-- server::script(path)
+
+func main() {
+    code := flag.Int("exit-code", 0, "Exit code after /stop")
+    mod := flag.Bool("mod", false, "Log chat and deaths like the mod does")
+    delay := flag.Duration("startup-delay", 0, "Time to \"load the world\"")
+    script := flag.String(
+        "script", "", "File with commands to run after the startup",
+    )
+    tellraw := flag.String(
+        "tellraw-log", "", "File to record the /tellraw commands into",
+    )
+    flag.Parse()
+
+    srv := server{
+        out:   os.Stdout,
+        mod:   *mod,
+        code:  *code,
+        teams: make(map[string][]string),
+    }
+
+    if len(*tellraw) != 0 {
+        file, err := os.OpenFile(
+            *tellraw, os.O_WRONLY | os.O_CREATE | os.O_APPEND, 0644,
+        )
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        defer file.Close()
+        srv.tellraw = file
+    }
+
+    srv.startup(*delay)
+    if len(*script) != 0 {
+        if err := srv.script(*script); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+    }
+
+    scanner := bufio.NewScanner(os.Stdin)
+    scanner.Buffer(nil, 1 << 20)
+    for scanner.Scan() {
+        srv.command(scanner.Text())
+    }
+
+    // stdin closed, like the real server we just keep running
+    select {}
+} // <-- main()
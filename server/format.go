@@ -0,0 +1,140 @@
+// format.go
+// Formatting of the chat messages from Telegram
+package server
+
+import (
+    "slices"
+    "strings"
+    "unicode/utf8"
+)
+
+// Formatting of a part of a chat message
+type Style struct {
+    // Byte offsets of the part in the message
+    Start         int
+    End           int
+    Bold          bool
+    Italic        bool
+    Underlined    bool
+    Strikethrough bool
+    // Hidden until hovered
+    Spoiler       bool
+    // Inline code or a code block
+    Code          bool
+    // Clicking the part opens the URL
+    Url           string
+
+    // The part mentions this player
+    mention       string
+} // <-- struct Style
+
+// Longest chat line shown in Minecraft, in characters. Players can't send
+// longer messages either
+const CHAT_LINE_WIDTH = 256
+
+// Byte ranges of the lines to show the text in: the text is split at the
+// line breaks, and the lines longer than CHAT_LINE_WIDTH are wrapped at the
+// last space that fits
+func wrap_lines(text string) [][2]int {
+    var ret [][2]int
+    start := 0
+    for {
+        line_end := len(text)
+        if i := strings.IndexByte(text[start:], '\n'); i >= 0 {
+            line_end = start + i
+        }
+
+        for utf8.RuneCountInString(text[start:line_end]) > CHAT_LINE_WIDTH {
+            end := start
+            for range CHAT_LINE_WIDTH {
+                _, n := utf8.DecodeRuneInString(text[end:])
+                end += n
+            }
+            next := end
+            if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+                end, next = start + i, start + i + 1
+            }
+            ret = append(ret, [2]int{ start, end })
+            start = next
+        }
+        ret = append(ret, [2]int{ start, line_end })
+
+        if line_end == len(text) {
+            return ret
+        }
+        start = line_end + 1
+    }
+} // <-- wrap_lines(text)
+
+// Styles of the part text[start:end], with offsets relative to it
+func slice_styles(styles []Style, start int, end int) []Style {
+    var ret []Style
+    for _, style := range styles {
+        style.Start = max(style.Start, start) - start
+        style.End = min(style.End, end) - start
+        if style.Start < style.End {
+            ret = append(ret, style)
+        }
+    }
+    return ret
+} // <-- slice_styles(styles, start, end)
+
+// Text component with the formatted text, the parts are in its `extra`
+func styled_tellraw(text string, styles []Style) tellraw_cmd {
+    // Split the text where the styles begin and end
+    cuts := []int{ 0, len(text) }
+    for _, style := range styles {
+        cuts = append(cuts, style.Start, style.End)
+    }
+    slices.Sort(cuts)
+    cuts = slices.Compact(cuts)
+
+    ret := tellraw_cmd{ Text: "", Color: "white" }
+    for i := 1; i < len(cuts); i++ {
+        from, to := cuts[i - 1], cuts[i]
+        part := tellraw_cmd{ Text: text[from:to], Color: "white" }
+        for _, style := range styles {
+            if style.Start <= from && to <= style.End {
+                apply_style(&part, style)
+            }
+        }
+        ret.Extra = append(ret.Extra, part)
+    }
+    return ret
+} // <-- styled_tellraw(text, styles)
+
+// Apply the style to the text component
+func apply_style(part *tellraw_cmd, style Style) {
+    part.Bold = part.Bold || style.Bold
+    part.Italic = part.Italic || style.Italic
+    part.Underlined = part.Underlined || style.Underlined
+    part.Strikethrough = part.Strikethrough || style.Strikethrough
+
+    // Mentions are the most important, then links, then code
+    if style.Code && part.Color == "white" {
+        part.Color = "gray"
+    }
+    if is_web_url(style.Url) && part.Color != "gold" {
+        part.Color = "aqua"
+        part.Underlined = true
+        part.ClickEvent = &tellraw_click{ Action: "open_url", Value: style.Url }
+        part.HoverEvent = hover_text(style.Url)
+    }
+    if len(style.mention) != 0 {
+        part.Color = "gold"
+        part.HoverEvent = hover_text(style.mention)
+    }
+    if style.Spoiler {
+        // Hovering reveals the text
+        part.Obfuscated = true
+        part.HoverEvent = hover_text(part.Text)
+    }
+} // <-- apply_style(part, style)
+
+// Check that the URL can be opened from the chat: Minecraft only allows http
+// and https links
+func is_web_url(url string) bool {
+    lower := strings.ToLower(url)
+    return strings.HasPrefix(lower, "http://") ||
+        strings.HasPrefix(lower, "https://")
+} // <-- is_web_url(url)
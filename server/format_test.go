@@ -0,0 +1,49 @@
+package server
+
+import (
+    "slices"
+    "strings"
+    "testing"
+)
+
+func TestWrapLines(t *testing.T) {
+    long_word := strings.Repeat("x", CHAT_LINE_WIDTH + 10)
+    words := strings.Repeat("word ", CHAT_LINE_WIDTH / 5) + "end"
+    tests := []struct {
+        name string
+        text string
+        want []string
+    }{
+        { "empty", "", []string{ "" } },
+        { "lines", "a\nb\n", []string{ "a", "b", "" } },
+        {
+            "long word", long_word,
+            []string{ long_word[:CHAT_LINE_WIDTH], "xxxxxxxxxx" },
+        },
+        {
+            "at space", words,
+            []string{ strings.TrimSuffix(words, " end"), "end" },
+        },
+        {
+            // Characters, not bytes, are counted
+            "multibyte", strings.Repeat("é", CHAT_LINE_WIDTH) + "\nok",
+            []string{ strings.Repeat("é", CHAT_LINE_WIDTH), "ok" },
+        },
+        {
+            "invalid utf-8", strings.Repeat("\xff", CHAT_LINE_WIDTH + 1),
+            []string{ strings.Repeat("\xff", CHAT_LINE_WIDTH), "\xff" },
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            var got []string
+            for _, line := range wrap_lines(test.text) {
+                got = append(got, test.text[line[0]:line[1]])
+            }
+            if !slices.Equal(got, test.want) {
+                t.Errorf("got %q, want %q", got, test.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,106 @@
+// mentions.go
+// Mentions of players in the messages from Telegram
+package server
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// How the mentioned players are notified
+type MentionConfig struct {
+    // Sound played to the mentioned player, e.g.
+    // "minecraft:block.note_block.bell". Empty for none
+    Sound  string `json:"sound,omitempty"`
+    // Also show a "title" or an "actionbar" notification. Empty for none
+    Notify string `json:"notify,omitempty"`
+} // <-- struct MentionConfig
+
+// Check the config values
+func (self *MentionConfig) Validate() []error {
+    switch self.Notify {
+    case "", "title", "actionbar":
+        return nil
+    }
+    return []error{
+        errors.New(`mentions.notify: must be "title", "actionbar" or empty`),
+    }
+} // <-- MentionConfig::Validate()
+
+// Mention of a player or a Telegram user: `@name`. Shared by both relay
+// directions so that they agree on what a mention is
+var MentionRegexp = regexp.MustCompile(`@[A-Za-z0-9_]+`)
+
+// Online player mentioned with `@name`: the name is either the player's
+// name or the Telegram username bound to the player (case-insensitive)
+func (self *Handle) mentioned_player(word string) (string, bool) {
+    name := strings.TrimPrefix(word, "@")
+    for _, player := range self.players_online {
+        if strings.EqualFold(player, name) {
+            return player, true
+        }
+    }
+    for _, team := range self.teams.Data {
+        tg_name, is_rename := strings.CutPrefix(team.Name, RENAME_TEAM_PFX)
+        if !is_rename || !strings.EqualFold(tg_name, name) {
+            continue
+        }
+        for _, player := range team.Usernames {
+            for _, online := range self.players_online {
+                if player == online {
+                    return player, true
+                }
+            }
+        }
+    }
+    return "", false
+} // <-- Handle::mentioned_player(word)
+
+// Styles highlighting the mentions of online players in the text. Also
+// returns the mentioned players
+func (self *Handle) mention_styles(text string) ([]Style, []string) {
+    var styles []Style
+    var players []string
+    for _, loc := range MentionRegexp.FindAllStringIndex(text, -1) {
+        player, found := self.mentioned_player(text[loc[0]:loc[1]])
+        if !found {
+            continue
+        }
+        styles = append(styles, Style{ Start: loc[0], End: loc[1], mention: player })
+        players = append(players, player)
+    }
+    return styles, players
+} // <-- Handle::mention_styles(text)
+
+// Console commands notifying the player that `author` mentioned them
+func ping_commands(config MentionConfig, player string, author string) []string {
+    var ret []string
+    if len(config.Sound) != 0 {
+        ret = append(
+            ret, fmt.Sprintf("/playsound %s master %s", config.Sound, player),
+        )
+    }
+
+    title := func(kind string, text string, color string) string {
+        js, _ := json.Marshal(tellraw_cmd{ Text: text, Color: color })
+        return fmt.Sprintf("/title %s %s %s", player, kind, js)
+    } // <-- title(kind, text, color)
+
+    switch config.Notify {
+    case "title":
+        // The subtitle is shown together with the next title
+        ret = append(
+            ret,
+            title("subtitle", "mentioned you in Telegram", "gray"),
+            title("title", "@" + author, "blue"),
+        )
+    case "actionbar":
+        ret = append(
+            ret, title("actionbar", "@" + author + " mentioned you in Telegram", "blue"),
+        )
+    }
+    return ret
+} // <-- ping_commands(config, player, author)
@@ -0,0 +1,173 @@
+// parser.go
+// Turn the server's console output into events
+package server
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// Server console line parser. Has no side effects: the events it produces are
+// applied to the server state by whoever consumes them
+type Parser struct {
+    message_r     *regexp.Regexp
+    raw_message_r *regexp.Regexp
+    death_r       *regexp.Regexp
+    teams_r       *regexp.Regexp
+    team_r        *regexp.Regexp
+    joined_r      *regexp.Regexp
+    left_r        *regexp.Regexp
+    done_r        *regexp.Regexp
+    achievement_r *regexp.Regexp
+    list_r        *regexp.Regexp
+    error_r       *regexp.Regexp
+} // <-- struct Parser
+
+func MakeParser() *Parser {
+    // I'm sorry for what's about to follow, my precious 80-column line limit :(
+    return &Parser{
+        message_r:     regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]:( \[Not Secure\])* \<([A-Za-z0-9_\.]+)\> (.*)\r*\n$`,
+        ),
+        raw_message_r: regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[co.gr.mc.MCTGMod\/\]: CHAT([A-Za-z0-9_\.]+)(.*)\r*\n$`,
+        ),
+        death_r:       regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[co.gr.mc.MCTGMod\/\]: DEATH([A-Za-z0-9_\.]+)(.*)\r*\n$`,
+        ),
+        teams_r:       regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: There are ([0-9]+) team\(s\): (.+)\r*\n$`,
+        ),
+        team_r:        regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: Team (.+) has ([0-9]+) member\(s\): (.+)\r*\n$`,
+        ),
+        joined_r:      regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: ([A-Za-z0-9_\.]+) joined the game\r*\n$`,
+        ),
+        left_r:        regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: ([A-Za-z0-9_\.]+) left the game\r*\n$`,
+        ),
+        done_r:        regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/DedicatedServer\]: Done \([0-9]+\.[0-9]+s\)! For help, type "help"\r*\n$`,
+        ),
+        achievement_r: regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: ([A-Za-z0-9_\.]+) has made the advancement \[(.*)\]\r*\n$`,
+        ),
+        // Both the 1.13+ and the older format
+        list_r:        regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/(?:MinecraftServer|DedicatedServer)\]: There are ([0-9]+)(?: of a max of |\/)([0-9]+) players online:(.*)\r*\n$`,
+        ),
+        error_r:       regexp.MustCompile(
+            `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[([^\]]+)\/(ERROR|FATAL)\] \[([^\]]*)\]: (.*)\r*\n$`,
+        ),
+    }
+} // <-- MakeParser()
+
+// Check if the console line continues the record, i.e. has no [HH:MM:SS]
+// header and the record can have more lines: an error with its stack trace or
+// the output of a command. Chat, deaths and other player events are single
+// lines, whatever is printed after them (mods writing to System.out, JVM
+// warnings) must not end up in the players' messages
+func (self *Parser) is_continuation(record string, line string) bool {
+    if record_header_r.MatchString(line) {
+        return false
+    }
+    header := record[:strings.IndexByte(record, '\n') + 1]
+    if self.error_r.MatchString(header) {
+        return true
+    }
+    for _, r := range []*regexp.Regexp{
+        self.message_r, self.raw_message_r, self.death_r, self.teams_r,
+        self.team_r, self.joined_r, self.left_r, self.done_r,
+        self.achievement_r, self.list_r,
+    } {
+        if r.MatchString(header) {
+            return false
+        }
+    }
+    return true
+} // <-- Parser::is_continuation(record, line)
+
+var record_header_r = regexp.MustCompile(`^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] `)
+
+// Parse a single console record: a line (including the trailing newline),
+// possibly followed by continuation lines. Returns the output events and the
+// internal events that should be produced for it
+func (self *Parser) Parse(record string) []any {
+    ret := []any{ OutputEventLog{ Message: record } }
+
+    // The header line decides what the record is, only errors have
+    // continuation lines (the stack trace)
+    first, rest := split_record(record)
+    str := first + "\n"
+
+    if sm := self.message_r.FindStringSubmatch(str); sm != nil {
+        ret = append(ret, OutputEventMessage{
+            Tellraw:  false,
+            Username: sm[2],
+            Message:  sm[3],
+        })
+    } else if sm := self.raw_message_r.FindStringSubmatch(str); sm != nil {
+        ret = append(
+            ret,
+            OutputEventLog{
+                Message: fmt.Sprintf("%s: %s\n", sm[1], sm[2]),
+            },
+            OutputEventMessage{
+                Tellraw:  true,
+                Username: sm[1],
+                Message:  sm[2],
+            },
+        )
+    } else if sm := self.death_r.FindStringSubmatch(str); sm != nil {
+        ret = append(ret, OutputEventPlayerDeath{
+            Username: sm[1],
+            Message:  sm[2],
+        })
+    } else if sm := self.teams_r.FindStringSubmatch(str); sm != nil {
+        var teams []string
+        for _, team := range strings.Split(sm[2], ", ") {
+            teams = append(teams, team[1:len(team)-1])
+        }
+        ret = append(ret, input_event_team_list{ Teams: teams })
+    } else if sm := self.team_r.FindStringSubmatch(str); sm != nil {
+        ret = append(ret, input_event_update_team{
+            Team:      sm[1][1:len(sm[1])-1],
+            Usernames: strings.Split(sm[3], ", "),
+        })
+    } else if sm := self.joined_r.FindStringSubmatch(str); sm != nil {
+        ret = append(ret, OutputEventPlayerJoined{
+            Username: sm[1],
+        })
+    } else if sm := self.left_r.FindStringSubmatch(str); sm != nil {
+        ret = append(ret, OutputEventPlayerLeft{
+            Username: sm[1],
+        })
+    } else if sm := self.achievement_r.FindStringSubmatch(str); sm != nil {
+        ret = append(ret, OutputEventPlayerAchievement{
+            Username:    sm[1],
+            Achievement: sm[2],
+        })
+    } else if sm := self.list_r.FindStringSubmatch(str); sm != nil {
+        players := []string{}
+        for _, player := range strings.Split(sm[3], ",") {
+            if player = strings.TrimSpace(player); len(player) != 0 {
+                players = append(players, player)
+            }
+        }
+        ret = append(ret, input_event_player_list{ Players: players })
+    } else if sm := self.done_r.FindStringSubmatch(str); sm != nil {
+        ret = append(ret, OutputEventServerLoaded{})
+    } else if sm := self.error_r.FindStringSubmatch(str); sm != nil {
+        ret = append(ret, OutputEventServerError{
+            Level:   sm[2],
+            Thread:  sm[1],
+            Logger:  strings.TrimSuffix(sm[3], "/"),
+            Message: sm[4],
+            Trace:   rest,
+        })
+    }
+
+    return ret
+} // <-- Parser::Parse(record)
@@ -0,0 +1,83 @@
+package server
+
+import (
+    "slices"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestParseServerLoaded(t *testing.T) {
+    const header = "[12:00:00] [Server thread/INFO] [minecraft/DedicatedServer]: "
+    tests := []struct {
+        name   string
+        record string
+        loaded bool
+    }{
+        { "fast", header + "Done (1.234s)! For help, type \"help\"\n", true },
+        { "slow", header + "Done (12.345s)! For help, type \"help\"\n", true },
+        { "very slow", header + "Done (123.456s)! For help, type \"help\"\n", true },
+        { "no time", header + "Done (s)! For help, type \"help\"\n", false },
+        { "other", header + "Starting Minecraft server on *:25565\n", false },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            loaded := slices.ContainsFunc(MakeParser().Parse(test.record), func(event any) bool {
+                _, is_loaded := event.(OutputEventServerLoaded)
+                return is_loaded
+            })
+            if loaded != test.loaded {
+                t.Errorf("server loaded = %v, want %v", loaded, test.loaded)
+            }
+        })
+    }
+} // <-- TestParseServerLoaded
+
+func TestParseContinuationLines(t *testing.T) {
+    const info = "[12:00:00] [Server thread/INFO] [minecraft/MinecraftServer]: "
+    const mod = "[12:00:00] [Server thread/INFO] [co.gr.mc.MCTGMod/]: "
+    const err_line = "[12:00:00] [Server thread/ERROR] [minecraft/MinecraftServer]: "
+    tests := []struct {
+        name    string
+        log     string
+        records []string
+    }{
+        {
+            "chat", info + "<Steve> hi\nsome mod output\n",
+            []string{ info + "<Steve> hi\n", "some mod output\n" },
+        },
+        {
+            "death", mod + "\x1bDEATH\x1bSteve\x1bfell\n\tat nothing\n",
+            []string{ mod + "\x1bDEATH\x1bSteve\x1bfell\n", "\tat nothing\n" },
+        },
+        {
+            "error", err_line + "Oops\njava.lang.Exception: oops\n\tat a.b(c.java:1)\n",
+            []string{ err_line + "Oops\njava.lang.Exception: oops\n\tat a.b(c.java:1)\n" },
+        },
+        {
+            "command output", info + "Steve has the following entity data:\n{\n}\n",
+            []string{ info + "Steve has the following entity data:\n{\n}\n" },
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            parser := MakeParser()
+            var records []string
+            read_records(
+                strings.NewReader(test.log), parser.is_continuation, time.Minute,
+                func(record string) { records = append(records, record) },
+            )
+            if !slices.Equal(records, test.records) {
+                t.Errorf("records = %q, want %q", records, test.records)
+            }
+        })
+    }
+
+    // Nothing after the chat line gets into the message
+    record := info + "<Steve> hi\n"
+    for _, event := range MakeParser().Parse(record) {
+        if msg, is_msg := event.(OutputEventMessage); is_msg && msg.Message != "hi" {
+            t.Errorf("message = %q, want \"hi\"", msg.Message)
+        }
+    }
+} // <-- TestParseContinuationLines
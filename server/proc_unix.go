@@ -0,0 +1,27 @@
+//go:build unix
+
+// proc_unix.go
+// Process group handling on Unix
+package server
+
+import (
+    "os/exec"
+    "syscall"
+)
+
+// Run the server in its own process group, so that signals reach the whole
+// process tree (e.g. java started by run.sh) and the terminal's Ctrl-C
+// doesn't
+func setup_process(cmd *exec.Cmd) {
+    cmd.SysProcAttr = &syscall.SysProcAttr{ Setpgid: true }
+} // <-- setup_process(cmd)
+
+// Ask the server's process group to terminate
+func terminate_process(cmd *exec.Cmd) error {
+    return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+} // <-- terminate_process(cmd)
+
+// Kill the server's process group
+func kill_process(cmd *exec.Cmd) error {
+    return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+} // <-- kill_process(cmd)
@@ -0,0 +1,20 @@
+//go:build windows
+
+// proc_windows.go
+// Process handling on Windows
+package server
+
+import (
+    "os/exec"
+)
+
+func setup_process(cmd *exec.Cmd) {}
+
+// There are no signals on Windows, the best we can do is to kill the process
+func terminate_process(cmd *exec.Cmd) error {
+    return cmd.Process.Kill()
+} // <-- terminate_process(cmd)
+
+func kill_process(cmd *exec.Cmd) error {
+    return cmd.Process.Kill()
+} // <-- kill_process(cmd)
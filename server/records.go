@@ -0,0 +1,87 @@
+// records.go
+// Group console lines into multi-line records
+package server
+
+import (
+    "bufio"
+    "io"
+    "time"
+)
+
+// Time to wait for continuation lines before the last record is considered
+// complete
+const RECORD_IDLE = 100 * time.Millisecond
+
+// Read `r` line by line and group every line for which `is_continuation`
+// returns true with the record before it (all its lines so far). `emit` receives each complete record
+// (all lines including the trailing newlines). A record is complete when
+// the next non-continuation line arrives, or when no new lines arrive for
+// `idle` (0 to only wait for the next line, e.g. when reading a file).
+// Returns the error that stopped reading, io.EOF at the end of input
+func read_records(
+    r io.Reader,
+    is_continuation func(record string, line string) bool,
+    idle time.Duration,
+    emit func(record string),
+) error {
+    type line_t struct {
+        str string
+        err error
+    }
+
+    lines := make(chan line_t)
+    // Tells the reader goroutine to quit early
+    done  := make(chan struct{})
+    defer close(done)
+
+    go func() {
+        reader := bufio.NewReader(r)
+        for {
+            str, err := reader.ReadString('\n')
+            select {
+            case lines <- line_t{ str, err }:
+            case <-done:
+                return
+            }
+            if err != nil {
+                return
+            }
+        }
+    } ()
+
+    pending := ""
+    flush := func() {
+        if len(pending) != 0 {
+            emit(pending)
+            pending = ""
+        }
+    } // <-- flush()
+
+    for {
+        var timeout <-chan time.Time
+        if len(pending) != 0 && idle != 0 {
+            timeout = time.After(idle)
+        }
+
+        select {
+        case <-timeout:
+            flush()
+        case line := <-lines:
+            if len(line.str) != 0 {
+                if len(pending) != 0 && is_continuation(pending, line.str) {
+                    pending += line.str
+                } else {
+                    flush()
+                    pending = line.str
+                }
+            }
+            if line.err != nil {
+                if len(pending) != 0 && pending[len(pending) - 1] != '\n' {
+                    pending += "\n"
+                }
+                flush()
+                return line.err
+            }
+        }
+    }
+} // <-- read_records(r, is_continuation, idle, emit)
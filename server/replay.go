@@ -0,0 +1,43 @@
+// replay.go
+// Run the parser over an existing server log
+package server
+
+import (
+    "io"
+)
+
+// Feed the console log through the parser as if the server printed it. The
+// server state (players online, teams) is updated, but no process is started
+// and nothing is written to the server. `handle` receives every record
+// (a line with its continuation lines) and the output events parsed from it
+func (self *Handle) Replay(
+    r io.Reader, handle func(record string, events []any),
+) error {
+    parser := MakeParser()
+
+    emit := func(record string) {
+        self.push_log(record)
+
+        var events []any
+        for _, event := range parser.Parse(record) {
+            out, requests := self.dispatch(event)
+            // There is no server to answer the requests, only the team
+            // updates already parsed from the log are applied
+            for _, request := range requests {
+                if update, is_update := request.(input_event_update_team); is_update {
+                    self.update_team(update)
+                }
+            }
+            events = append(events, out...)
+        }
+        handle(record, events)
+    } // <-- emit(record)
+
+    // A pause in reading (a slow disk, decompression) is not the end of a
+    // record, only the next line is
+    err := read_records(r, parser.is_continuation, 0, emit)
+    if err == io.EOF {
+        return nil
+    }
+    return err
+} // <-- Handle::Replay(r, handle)
@@ -0,0 +1,78 @@
+package server
+
+import (
+    "io"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestReplay(t *testing.T) {
+    const header = "[12:00:00] [Server thread/INFO] [minecraft/MinecraftServer]: "
+    log := strings.Join([]string{
+        "[12:00:00] [Server thread/INFO] [minecraft/DedicatedServer]: Done (1.234s)! For help, type \"help\"",
+        header + "There are 1 team(s): [red]",
+        header + "Team [red] has 2 member(s): Steve, Alex",
+        header + "Steve joined the game",
+        header + "Alex joined the game",
+        header + "Steve left the game",
+        header + "There are 1 of a max of 20 players online: Alex",
+    }, "\n") + "\n"
+
+    handle := MakeHandle(Config{})
+    var events []any
+    err := handle.Replay(
+        strings.NewReader(log),
+        func(record string, parsed []any) {
+            for _, event := range parsed {
+                if _, is_log := event.(OutputEventLog); !is_log {
+                    events = append(events, event)
+                }
+            }
+        },
+    )
+    if err != nil {
+        t.Fatalf("Replay: %v", err)
+    }
+
+    want_events := []any{
+        OutputEventServerLoaded{},
+        OutputEventPlayerJoined{ Username: "Steve" },
+        OutputEventPlayerJoined{ Username: "Alex" },
+        OutputEventPlayerLeft{ Username: "Steve" },
+    }
+    if !reflect.DeepEqual(events, want_events) {
+        t.Errorf("events = %#v, want %#v", events, want_events)
+    }
+    if want := []string{ "Alex" }; !reflect.DeepEqual(handle.players_online, want) {
+        t.Errorf("players online = %v, want %v", handle.players_online, want)
+    }
+    want_teams := []Team{ { Name: "red", Usernames: []string{ "Steve", "Alex" } } }
+    if !reflect.DeepEqual(handle.teams.Data, want_teams) {
+        t.Errorf("teams = %v, want %v", handle.teams.Data, want_teams)
+    }
+} // <-- TestReplay
+
+// A pause in the input doesn't split a record
+func TestReplaySlowReader(t *testing.T) {
+    r, w := io.Pipe()
+    go func() {
+        io.WriteString(w, "[12:00:00] [Server thread/ERROR] [minecraft/MinecraftServer]: Oops\n")
+        time.Sleep(2 * RECORD_IDLE)
+        io.WriteString(w, "java.lang.Exception: oops\n")
+        w.Close()
+    }()
+
+    handle := MakeHandle(Config{})
+    var records []string
+    err := handle.Replay(r, func(record string, events []any) {
+        records = append(records, record)
+    })
+    if err != nil {
+        t.Fatalf("Replay: %v", err)
+    }
+    if len(records) != 1 {
+        t.Errorf("records = %q, want one", records)
+    }
+} // <-- TestReplaySlowReader
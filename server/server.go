@@ -3,38 +3,115 @@
 package server
 
 import (
-    "bufio"
     "encoding/json"
+    "errors"
     "fmt"
     "io"
     "log"
+    "os"
     "os/exec"
-    "regexp"
+    "slices"
     "strings"
+    "sync"
+    "sync/atomic"
+    "time"
 )
 
 const RENAME_TEAM_PFX = "__internal_rename_"
 
+// Server lifecycle states
+const (
+    // No server process
+    HS_STOPPED     = iota
+    // The process is started, but the world is not loaded yet
+    HS_STARTING    = iota
+    HS_RUNNING     = iota
+    // /stop has been sent
+    HS_STOPPING    = iota
+    // The server ignored /stop, SIGTERM has been sent
+    HS_TERMINATING = iota
+    // SIGKILL has been sent
+    HS_KILLING     = iota
+)
+
+// Defaults for Config.StopTimeout and Config.TermTimeout
+const (
+    DEFAULT_STOP_TIMEOUT = 60
+    DEFAULT_TERM_TIMEOUT = 15
+)
+
 // Server config
 type Config struct {
     // Command-line to run the Minecraft server
-    Cmdline  []string `json:"cmdline"`
+    Cmdline  []string `json:"cmdline" reload:"restart"`
     // Log lines to store in RAM
     LogLines uint     `json:"log_lines"`
+    // Working directory for the server. Empty for the bot's own
+    Workdir  string   `json:"workdir,omitempty" reload:"restart"`
+    // Seconds to wait for the server to exit after /stop before sending
+    // SIGTERM. 0 for the default
+    StopTimeout uint  `json:"stop_timeout,omitempty"`
+    // Seconds to wait for the server to exit after SIGTERM before sending
+    // SIGKILL. 0 for the default
+    TermTimeout uint  `json:"term_timeout,omitempty"`
+    // Hang detection
+    Watchdog WatchdogConfig `json:"watchdog"`
+    // Notifications for the players mentioned from Telegram
+    Mentions MentionConfig  `json:"mentions"`
 } // <-- struct Config
 
-// tellraw command
-type tellraw_cmd struct {
-    Text  string `json:"text"`
-    Color string `json:"color"`
-}
+// Time to wait for the server to exit after /stop
+func (self *Config) StopWait() time.Duration {
+    if self.StopTimeout == 0 {
+        return DEFAULT_STOP_TIMEOUT * time.Second
+    }
+    return time.Duration(self.StopTimeout) * time.Second
+} // <-- Config::StopWait()
+
+// Time to wait for the server to exit after SIGTERM
+func (self *Config) TermWait() time.Duration {
+    if self.TermTimeout == 0 {
+        return DEFAULT_TERM_TIMEOUT * time.Second
+    }
+    return time.Duration(self.TermTimeout) * time.Second
+} // <-- Config::TermWait()
+
+// Check the config values
+func (self *Config) Validate() []error {
+    var errs []error
+    if len(self.Cmdline) == 0 || len(self.Cmdline[0]) == 0 {
+        errs = append(errs, errors.New("cmdline: must not be empty"))
+    }
+    if len(self.Workdir) != 0 {
+        if info, err := os.Stat(self.Workdir); err != nil {
+            errs = append(errs, fmt.Errorf("workdir: %w", err))
+        } else if !info.IsDir() {
+            errs = append(
+                errs, fmt.Errorf("workdir: %s is not a directory", self.Workdir),
+            )
+        }
+    }
+    errs = append(errs, self.Mentions.Validate()...)
+    return errs
+} // <-- Config::Validate()
 
 // Handle for a single Minecraft server instance
 type Handle struct {
     // Server handler config
     config         Config
+    // Guards `config`, which can be replaced by Reload()
+    config_mu      sync.RWMutex
     // Process command handle
     cmd            *exec.Cmd
+    // Closed when the process exits
+    exited         chan struct{}
+    // Lifecycle state, one of HS_*. Changed from several goroutines, only
+    // through raise_state() and CompareAndSwap
+    state          atomic.Uint32
+    // When the process was started
+    started        time.Time
+    // The server has answered a /list, for the watchdog
+    probe_ack      chan struct{}
     // A list of active players
     players_online []string
     // Channel with the server's output
@@ -45,8 +122,14 @@ type Handle struct {
     // Teams on the server
     teams          TeamMapping
 
+    // The last `config.LogLines` console records
+    log_buffer     []string
+    log_mu         sync.Mutex
+
     // Child process stdout
     stdout         *io.ReadCloser
+    // Child process stderr
+    stderr         *io.ReadCloser
     // Child process stdin
     stdin          *io.WriteCloser
 
@@ -54,6 +137,21 @@ type Handle struct {
     TryRestart     bool
 } // <-- struct Handle
 
+// Get the current config
+func (self *Handle) cfg() Config {
+    self.config_mu.RLock()
+    defer self.config_mu.RUnlock()
+    return self.config
+} // <-- Handle::cfg()
+
+// Replace the config. Fields marked with `reload:"restart"` only take effect
+// when the server is restarted
+func (self *Handle) Reload(server_cfg Config) {
+    self.config_mu.Lock()
+    defer self.config_mu.Unlock()
+    self.config = server_cfg
+} // <-- Handle::Reload(server_cfg)
+
 // The player has joined the server
 func (self *Handle) push_player(username string) {
     found := false
@@ -78,6 +176,24 @@ func (self *Handle) pop_player(username string) {
     }
 } // <-- func Handle::pop_player(username)
 
+// Remember the console record in the log buffer
+func (self *Handle) push_log(record string) {
+    self.log_mu.Lock()
+    defer self.log_mu.Unlock()
+
+    self.log_buffer = append(self.log_buffer, record)
+    if limit := int(self.cfg().LogLines); len(self.log_buffer) > limit {
+        self.log_buffer = self.log_buffer[len(self.log_buffer) - limit:]
+    }
+} // <-- Handle::push_log(record)
+
+// Get the last console records (up to `log_lines` from the config)
+func (self *Handle) RecentLog() []string {
+    self.log_mu.Lock()
+    defer self.log_mu.Unlock()
+    return append([]string(nil), self.log_buffer...)
+} // <-- Handle::RecentLog()
+
 // Get the server's output channel
 func (self *Handle) Out() <-chan any {
     return self.out
@@ -90,15 +206,19 @@ func (self *Handle) In() chan<- any {
 
 // Handle writing to the server's stdin
 func (self *Handle) handle_stdin() {
-    say := func(cmd []tellraw_cmd) {
+    // Show the message to `target` (a player or a selector like @a)
+    say := func(target string, cmd []tellraw_cmd) {
         if str, err := json.Marshal(cmd); err == nil {
-            fmt.Fprintf(*self.stdin, "/tellraw @a %s\n", str)
+            fmt.Fprintf(*self.stdin, "/tellraw %s %s\n", target, str)
         } else {
             self.out <- OutputEventError{ &Error{ ERR_TRAWJS } }
         }
-    } // <-- say(cmd)
+    } // <-- say(target, cmd)
 
-    make_tellraw := func(usr string, t string, tg bool, e bool) []tellraw_cmd {
+    // `note` is shown after the user's name, e.g. "(edited)". nil for none
+    make_tellraw := func(
+        usr string, body []tellraw_cmd, tg bool, note *tellraw_cmd,
+    ) []tellraw_cmd {
         ret := []tellraw_cmd{ { Text: "@", Color: "gold" } }
 
         if tg {
@@ -106,17 +226,14 @@ func (self *Handle) handle_stdin() {
         }
 
         ret = append(ret, tellraw_cmd{ Text: usr, Color: "yellow" })
-        if e {
-            ret = append(
-                ret,
-                tellraw_cmd{ Text: " corrects", Color: "dark_gray" },
-            )
+        if note != nil {
+            ret = append(ret, tellraw_cmd{ Text: " ", Color: "white" }, *note)
         }
         ret = append(ret, tellraw_cmd{ Text: ": ", Color: "white" })
-        ret = append(ret, tellraw_cmd{ Text: t, Color: "white" })
+        ret = append(ret, body...)
 
         return ret
-    } // <-- make_tellraw(user, text, tg)
+    } // <-- make_tellraw(user, body, tg, note)
 
     username := func(usr string) string {
         team := fmt.Sprintf("%s%s", RENAME_TEAM_PFX, usr)
@@ -144,27 +261,103 @@ func (self *Handle) handle_stdin() {
         switch event := ie.(type) {
         case input_event_fetch_teams:
             fmt.Fprintf(*self.stdin, "/team list\n")
+        case input_event_probe:
+            fmt.Fprintf(*self.stdin, "/list\n")
         case input_event_req_team:
             fmt.Fprintf(*self.stdin, "/team list %s\n", event.Team)
         case input_event_update_team:
-            self.teams.Data = append(
-                self.teams.Data, Team{
-                    Name:      event.Team,
-                    Usernames: event.Usernames,
-                },
-            )
+            self.update_team(event)
             log.Println(self.teams)
         case InputEventChat:
-            for _, l := range strings.Split(event.Message, "\n") {
+            target := "@a"
+            var note *tellraw_cmd
+            if len(event.To) != 0 {
+                if !slices.Contains(self.players_online, event.To) {
+                    self.out <- OutputEventWhisperFailed{ Player: event.To }
+                    break
+                }
+                target = event.To
+                note = &tellraw_cmd{ Text: "whispers to you", Color: "dark_gray" }
+            }
+
+            styles := event.Styles
+            var mentioned []string
+            if event.Telegram {
+                var mentions []Style
+                mentions, mentioned = self.mention_styles(event.Message)
+                styles = append(slices.Clip(styles), mentions...)
+            }
+
+            for i, line := range wrap_lines(event.Message) {
+                l := event.Message[line[0]:line[1]]
+                body := []tellraw_cmd{
+                    styled_tellraw(l, slice_styles(styles, line[0], line[1])),
+                }
+                if i == 0 {
+                    // The reply and the media placeholder go before the text
+                    var head []tellraw_cmd
+                    if len(event.ReplyText) != 0 {
+                        author := event.ReplyTo
+                        if len(author) != 0 {
+                            author = username(author)
+                        }
+                        head = append(head, reply_tellraw(author, event.ReplyText))
+                    }
+                    if len(event.Media) != 0 {
+                        head = append(head, media_tellraw(event.Media, event.Link))
+                    }
+                    head = spaced(head)
+                    if len(l) != 0 || len(head) == 0 {
+                        if len(head) != 0 {
+                            head = append(head, tellraw_cmd{ Text: " ", Color: "white" })
+                        }
+                        head = append(head, body...)
+                    }
+                    body = head
+                }
                 say(
+                    target,
                     make_tellraw(
-                        username(event.Username), l, event.Telegram, false,
+                        username(event.Username), body, event.Telegram, note,
                     ),
                 )
             }
+
+            slices.Sort(mentioned)
+            for _, player := range slices.Compact(mentioned) {
+                // A whisper can't notify anyone but its recipient
+                if target != "@a" && player != target {
+                    continue
+                }
+                for _, cmd := range ping_commands(
+                    self.cfg().Mentions, player, username(event.Username),
+                ) {
+                    fmt.Fprintln(*self.stdin, cmd)
+                }
+            }
         case InputEventEditChat:
-            for _, l := range strings.Split(event.Message, "\n") {
-                say(make_tellraw(username(event.Username), l, true, true))
+            target := "@a"
+            if len(event.To) != 0 {
+                if !slices.Contains(self.players_online, event.To) {
+                    break // The original didn't reach them either
+                }
+                target = event.To
+            }
+
+            note := edited_tellraw(event.Original)
+            mentions, _ := self.mention_styles(event.Message)
+            styles := append(slices.Clip(event.Styles), mentions...)
+            for _, line := range wrap_lines(event.Message) {
+                body := []tellraw_cmd{
+                    styled_tellraw(
+                        event.Message[line[0]:line[1]],
+                        slice_styles(styles, line[0], line[1]),
+                    ),
+                }
+                say(
+                    target,
+                    make_tellraw(username(event.Username), body, true, &note),
+                )
             }
         case InputEventBindRename:
             team_name := fmt.Sprintf("%s%s", RENAME_TEAM_PFX, event.Username)
@@ -197,11 +390,16 @@ func (self *Handle) handle_stdin() {
             }
         case InputEventCommand:
             for _, l := range strings.Split(event.Command, "\n") {
+                cmd := strings.TrimSpace(l)
+                if cmd == "/stop" || cmd == "stop" {
+                    self.stop()
+                    continue
+                }
                 fmt.Fprintln(*self.stdin, l)
             }
         case InputEventKillServer:
             self.TryRestart = false
-            fmt.Fprintf(*self.stdin, "/stop\n")
+            self.stop()
         default:
             self.out <- OutputEventError{ &Error{ ERR_ETYPE } }
         }
@@ -210,103 +408,145 @@ func (self *Handle) handle_stdin() {
     log.Println("Exit server.Handle::handle_stdin()")
 } // <-- Handle::handle_stdin(stdin)
 
-// Handle reading from the server's stdout
+// Apply the parsed event to the server state. Returns the events to pass on
+// to the bot and the requests for the stdin handler it results in
+func (self *Handle) dispatch(event any) ([]any, []any) {
+    switch e := event.(type) {
+    case input_event_team_list:
+        self.teams = TeamMapping{}
+        var requests []any
+        for _, team := range e.Teams {
+            requests = append(requests, input_event_req_team{ Team: team })
+        }
+        return nil, requests
+    case input_event_update_team:
+        return nil, []any{ e }
+    case OutputEventPlayerJoined:
+        self.push_player(e.Username)
+    case OutputEventPlayerLeft:
+        self.pop_player(e.Username)
+    case input_event_player_list:
+        self.players_online = e.Players
+        select {
+        case self.probe_ack <- struct{}{}:
+        default:
+        }
+        return nil, nil
+    case OutputEventServerLoaded:
+        self.state.CompareAndSwap(HS_STARTING, HS_RUNNING)
+    }
+    return []any{ event }, nil
+} // <-- Handle::dispatch(event)
+
+// Remember the team's members
+func (self *Handle) update_team(event input_event_update_team) {
+    self.teams.Data = append(
+        self.teams.Data, Team{
+            Name:      event.Team,
+            Usernames: event.Usernames,
+        },
+    )
+} // <-- Handle::update_team(event)
+
+// Handle reading from the server's stdout. Lines without the [HH:MM:SS]
+// header (stack traces, multi-line command output) are grouped with the
+// preceding line into a single record before parsing
 func (self *Handle) handle_stdout() {
-    reader := bufio.NewReader(*self.stdout)
+    parser := MakeParser()
+    emit := func(record string) {
+        self.push_log(record)
+        for _, event := range parser.Parse(record) {
+            out, requests := self.dispatch(event)
+            for _, request := range requests {
+                self.in <- request
+            }
+            for _, e := range out {
+                self.out <- e
+            }
+        }
+    } // <-- emit(record)
 
-    // I'm sorry for what's about to follow, my precious 80-column line limit :(
-    message_r := regexp.MustCompile(
-        `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]:( \[Not Secure\])* \<([A-Za-z0-9_\.]+)\> (.*)\r*\n$`,
-    )
-    raw_message_r := regexp.MustCompile(
-        `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[co.gr.mc.MCTGMod\/\]: CHAT([A-Za-z0-9_\.]+)(.*)\r*\n$`,
-    )
-    death_r := regexp.MustCompile(
-        `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[co.gr.mc.MCTGMod\/\]: DEATH([A-Za-z0-9_\.]+)(.*)\r*\n$`,
-    )
-    teams_r := regexp.MustCompile(
-        `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: There are ([0-9]+) team\(s\): (.+)\r*\n$`,
-    )
-    team_r := regexp.MustCompile(
-        `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: Team (.+) has ([0-9]+) member\(s\): (.+)\r*\n$`,
-    )
-    joined_r := regexp.MustCompile(
-        `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: ([A-Za-z0-9_\.]+) joined the game\r*\n$`,
-    )
-    left_r := regexp.MustCompile(
-        `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: ([A-Za-z0-9_\.]+) left the game\r*\n$`,
-    )
-    done_r := regexp.MustCompile(
-        `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/DedicatedServer\]: Done \([0-9]\.[0-9]+s\)! For help, type "help"\r*\n$`,
-    )
-    achievement_r := regexp.MustCompile(
-        `^\[[0-9]{2}:[0-9]{2}:[0-9]{2}\] \[Server thread\/INFO\] \[minecraft\/MinecraftServer\]: ([A-Za-z0-9_\.]+) has made the advancement \[(.*)\]\r*\n$`,
+    err := read_records(
+        *self.stdout, parser.is_continuation, RECORD_IDLE, emit,
     )
+    if err != nil && err != io.EOF && self.cmd != nil {
+        self.out <- OutputEventError{ err }
+    }
 
-    for {
-        if self.cmd == nil {
-            break
+    self.stdout = nil
+    log.Println("Exit server.Handle::handle_stdout()")
+} // <-- Handle::handle_stdout(stdout)
+
+// Send /stop to the server and make sure it actually stops: escalate to
+// SIGTERM and then SIGKILL if it does not exit in time. Must be called from
+// the stdin handler
+func (self *Handle) stop() {
+    fmt.Fprintf(*self.stdin, "/stop\n")
+    if !self.raise_state(HS_STOPPING) {
+        return // Already escalating
+    }
+    go self.escalate(self.cmd, self.exited, self.cfg())
+} // <-- Handle::stop()
+
+// Escalate the stop of the process unless it exits first
+func (self *Handle) escalate(cmd *exec.Cmd, exited <-chan struct{}, config Config) {
+    steps := []struct {
+        state uint
+        wait  time.Duration
+        send  func(*exec.Cmd) error
+    }{
+        { HS_TERMINATING, config.StopWait(), terminate_process },
+        { HS_KILLING,     config.TermWait(), kill_process },
+    }
+
+    for _, step := range steps {
+        select {
+        case <-exited:
+            return
+        case <-time.After(step.wait):
         }
 
-        if str, err := reader.ReadString('\n'); err == nil {
-            self.out <- OutputEventLog{ str }
+        self.raise_state(step.state)
+        self.out <- OutputEventStopEscalation{
+            State: step.state,
+            After: step.wait,
+        }
+        if err := step.send(cmd); err != nil {
+            self.out <- OutputEventError{ err }
+        }
+    }
+} // <-- Handle::escalate(cmd, exited, config)
+
+// Handle reading from the server's stderr. Java stack traces are grouped into
+// single records
+func (self *Handle) handle_stderr() {
+    emit := func(record string) {
+        self.out <- OutputEventLog{ Message: record, Stderr: true }
+        if event := parse_stderr(record); event != nil {
+            self.out <- event
+        }
+    } // <-- emit(record)
 
-            if sm := message_r.FindStringSubmatch(str); sm != nil {
-                self.out <- OutputEventMessage{
-                    Tellraw:  false,
-                    Username: sm[2],
-                    Message:  sm[3],
-                }
-            } else if sm := raw_message_r.FindStringSubmatch(str); sm != nil {
-                self.out <- OutputEventLog{
-                    fmt.Sprintf("%s: %s\n", sm[1], sm[2]),
-                }
-                self.out <- OutputEventMessage{
-                    Tellraw:  true,
-                    Username: sm[1],
-                    Message:  sm[2],
-                }
-            } else if sm := death_r.FindStringSubmatch(str); sm != nil {
-                self.out <- OutputEventPlayerDeath{
-                    Username: sm[1],
-                    Message:  sm[2],
-                }
-            } else if sm := teams_r.FindStringSubmatch(str); sm != nil {
-                self.teams = TeamMapping{}
-                for _, team := range strings.Split(sm[2], ", ") {
-                    self.in <- input_event_req_team{ Team: team[1:len(team)-1] }
-                }
-            } else if sm := team_r.FindStringSubmatch(str); sm != nil {
-                self.in <- input_event_update_team{
-                    Team:      sm[1][1:len(sm[1])-1],
-                    Usernames: strings.Split(sm[3], ", "),
-                }
-            } else if sm := joined_r.FindStringSubmatch(str); sm != nil {
-                self.push_player(sm[1])
-                self.out <- OutputEventPlayerJoined{
-                    Username: sm[1],
-                }
-            } else if sm := left_r.FindStringSubmatch(str); sm != nil {
-                self.pop_player(sm[1])
-                self.out <- OutputEventPlayerLeft{
-                    Username: sm[1],
-                }
-            } else if sm := achievement_r.FindStringSubmatch(str); sm != nil {
-                self.out <- OutputEventPlayerAchievement{
-                    Username:    sm[1],
-                    Achievement: sm[2],
-                }
-            } else if sm := done_r.FindStringSubmatch(str); sm != nil {
-                self.out <- OutputEventServerLoaded{}
-            }
+    err := read_records(*self.stderr, is_trace_continuation, RECORD_IDLE, emit)
+    if err != nil && err != io.EOF && self.cmd != nil {
+        self.out <- OutputEventError{ err }
+    }
+
+    self.stderr = nil
+    log.Println("Exit server.Handle::handle_stderr()")
+} // <-- Handle::handle_stderr()
+
+// Send the summaries of the crash reports written since the server has
+// started
+func (self *Handle) report_crash() {
+    for _, path := range find_crash_reports(self.cfg().Workdir, self.started) {
+        if report, err := summarize_crash_report(path); err == nil {
+            self.out <- report
         } else {
             self.out <- OutputEventError{ err }
         }
     }
-
-    self.stdout = nil
-    log.Println("Exit server.Handle::handle_stdout()")
-} // <-- Handle::handle_stdout(stdout)
+} // <-- Handle::report_crash()
 
 // Monitor the server's state
 func (self *Handle) watch_child() {
@@ -315,18 +555,21 @@ func (self *Handle) watch_child() {
             // Wait for the child process to finish
             err := self.cmd.Wait()
             self.cmd = nil
+            self.state.Store(HS_STOPPED)
+            close(self.exited)
 
             // Send a dummy input event to ensure loop reaches termination
             self.in <- input_event_terminate{}
 
             // Wait for the IO handlers to finish
             for {
-                if self.stdin == nil && self.stdout == nil {
+                if self.stdin == nil && self.stdout == nil && self.stderr == nil {
                     break
                 }
             }
 
             if err != nil {
+                self.report_crash()
                 if exiterr, ok := err.(*exec.ExitError); ok {
                     return exiterr.ExitCode()
                 }
@@ -337,45 +580,92 @@ func (self *Handle) watch_child() {
     }
 } // <-- Handle::watch_child()
 
+// Kill the server process right away, without waiting for it to save the
+// world. The server is not restarted afterwards
+func (self *Handle) Kill() error {
+    self.TryRestart = false
+    cmd := self.cmd
+    if cmd == nil || cmd.Process == nil {
+        return nil
+    }
+    self.raise_state(HS_KILLING)
+    return kill_process(cmd)
+} // <-- Handle::Kill()
+
 // Check if the process is stopped
 func (self *Handle) IsRunning() bool { return self.cmd != nil }
 
+// Get the lifecycle state, one of HS_*
+func (self *Handle) State() uint { return uint(self.state.Load()) }
+
+// Move the lifecycle state forward to `state`. Returns false if it is already
+// there or further, e.g. the watchdog is killing the server being stopped
+func (self *Handle) raise_state(state uint) bool {
+    for {
+        current := self.state.Load()
+        if uint(current) >= state {
+            return false
+        }
+        if self.state.CompareAndSwap(current, uint32(state)) {
+            return true
+        }
+    }
+} // <-- Handle::raise_state(state)
+
 // Run the server command-line and attach reader and writer routines
 // This call is non-blocking and returns nil on success, error on failure
 func (self *Handle) Start() error {
     if self.cmd != nil {
         return &Error{ ERR_RUNNING }
     }
+    config := self.cfg()
+    if len(config.Cmdline) == 0 || len(config.Cmdline[0]) == 0 {
+        return &Error{ ERR_CMDLINE }
+    }
 
-    self.cmd = exec.Command(self.config.Cmdline[0], self.config.Cmdline[1:]...)
-    pipe_in, in_err := self.cmd.StdinPipe()
+    cmd := exec.Command(config.Cmdline[0], config.Cmdline[1:]...)
+    cmd.Dir = config.Workdir
+    setup_process(cmd)
+    pipe_in, in_err := cmd.StdinPipe()
     if in_err != nil {
         return in_err
     }
-    pipe_out, out_err := self.cmd.StdoutPipe()
+    pipe_out, out_err := cmd.StdoutPipe()
     if out_err != nil {
         return out_err
     }
+    pipe_err, err_err := cmd.StderrPipe()
+    if err_err != nil {
+        return err_err
+    }
 
-    // Start the process
-    if err := self.cmd.Start(); err != nil {
+    // Start the process. Only a started one makes the server running, a
+    // failed start can be retried
+    self.started = time.Now()
+    if err := cmd.Start(); err != nil {
         return err
     }
+    self.cmd = cmd
 
     self.TryRestart = true
+    self.state.Store(HS_STARTING)
+    self.exited     = make(chan struct{})
 
     // Handle the process IO
     self.stdin  = &pipe_in
     self.stdout = &pipe_out
+    self.stderr = &pipe_err
 
     // Update the teams
     fmt.Fprintf(*self.stdin, "/team list\n")
 
     go self.handle_stdin()
     go self.handle_stdout()
+    go self.handle_stderr()
 
     // Keepalive cycle
     go self.watch_child()
+    go self.watchdog(self.cmd, self.exited)
 
     return nil
 } // <-- Handle::Start()
@@ -389,6 +679,27 @@ func (self *Handle) ReverseRename(username string) string {
     return username
 } // <-- Handle::ReverseRename(username)
 
+// Telegram user bound to the name, which can be either a Minecraft player's
+// name or a Telegram username (both case-insensitive). Returns false if the
+// name is not bound to anyone
+func (self *Handle) TelegramUser(name string) (string, bool) {
+    for _, team := range self.teams.Data {
+        tg_name, is_rename := strings.CutPrefix(team.Name, RENAME_TEAM_PFX)
+        if !is_rename || len(team.Usernames) == 0 {
+            continue
+        }
+        if strings.EqualFold(tg_name, name) {
+            return tg_name, true
+        }
+        for _, player := range team.Usernames {
+            if strings.EqualFold(player, name) {
+                return tg_name, true
+            }
+        }
+    }
+    return "", false
+} // <-- Handle::TelegramUser(name)
+
 // Create server handle from the config
 func MakeHandle(server_cfg Config) Handle {
     return Handle{
@@ -396,6 +707,7 @@ func MakeHandle(server_cfg Config) Handle {
         cmd:        nil,
         out:        make(chan any),
         in:         make(chan any),
+        probe_ack:  make(chan struct{}, 1),
         TryRestart: true,
     }
 } // <-- MakeHandle(server_cfg)
@@ -0,0 +1,169 @@
+package server
+
+import (
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// Build the fake Minecraft server into a temporary directory
+func build_fakemc(t *testing.T) string {
+    if _, err := exec.LookPath("go"); err != nil {
+        t.Skip("go is not in PATH, can't build fakemc")
+    }
+    bin := filepath.Join(t.TempDir(), "fakemc")
+    if out, err := exec.Command("go", "build", "-o", bin, "./fakemc").CombinedOutput(); err != nil {
+        t.Fatalf("building fakemc: %v\n%s", err, out)
+    }
+    return bin
+} // <-- build_fakemc(t)
+
+// Read the output events until `match` accepts one
+func wait_event(t *testing.T, handle *Handle, what string, match func(any) bool) any {
+    t.Helper()
+    deadline := time.After(30 * time.Second)
+    for {
+        select {
+        case event := <-handle.Out():
+            if match(event) {
+                return event
+            }
+        case <-deadline:
+            t.Fatalf("timed out waiting for %s", what)
+        }
+    }
+} // <-- wait_event(t, handle, what, match)
+
+func TestSupervisor(t *testing.T) {
+    bin := build_fakemc(t)
+    tellraw_log := filepath.Join(t.TempDir(), "tellraw.log")
+
+    handle := MakeHandle(Config{
+        Cmdline: []string{ bin, "-mod", "-tellraw-log", tellraw_log },
+    })
+    if err := handle.Start(); err != nil {
+        t.Fatalf("Start: %v", err)
+    }
+    defer handle.Kill()
+
+    wait_event(t, &handle, "the server to load", func(event any) bool {
+        _, is_loaded := event.(OutputEventServerLoaded)
+        return is_loaded
+    })
+    if state := handle.State(); state != HS_RUNNING {
+        t.Errorf("state after loading = %d, want HS_RUNNING", state)
+    }
+
+    handle.In() <- InputEventCommand{ Command: "fake join Steve" }
+    wait_event(t, &handle, "Steve to join", func(event any) bool {
+        return event == OutputEventPlayerJoined{ Username: "Steve" }
+    })
+
+    handle.In() <- InputEventCommand{ Command: "fake chat Steve hello there" }
+    msg := wait_event(t, &handle, "Steve's message", func(event any) bool {
+        _, is_msg := event.(OutputEventMessage)
+        return is_msg
+    }).(OutputEventMessage)
+    if msg.Username != "Steve" || msg.Message != "hello there" {
+        t.Errorf("message = %+v, want \"hello there\" from Steve", msg)
+    }
+
+    // A whisper from Telegram only reaches Steve. Asking for the player
+    // list afterwards makes sure the stdin handler has written it
+    handle.In() <- InputEventChat{
+        Telegram: true,
+        Username: "alice",
+        Message:  "psst",
+        To:       "Steve",
+    }
+    handle.In() <- InputEventListPlayers{}
+    list := wait_event(t, &handle, "the player list", func(event any) bool {
+        _, is_list := event.(OutputEventListPlayers)
+        return is_list
+    }).(OutputEventListPlayers)
+    if len(list.PlayersOnline) != 1 || list.PlayersOnline[0] != "Steve" {
+        t.Errorf("players online = %v, want [Steve]", list.PlayersOnline)
+    }
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        data, _ := os.ReadFile(tellraw_log)
+        if strings.HasPrefix(string(data), "Steve ") &&
+            strings.Contains(string(data), "psst") {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("tellraw log = %q, want a whisper to Steve", data)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    handle.In() <- InputEventCommand{ Command: "/stop" }
+    exit := wait_event(t, &handle, "the server to exit", func(event any) bool {
+        _, is_exit := event.(OutputEventExit)
+        return is_exit
+    }).(OutputEventExit)
+    if exit.ExitCode != 0 {
+        t.Errorf("exit code = %d, want 0", exit.ExitCode)
+    }
+    if handle.IsRunning() {
+        t.Error("the server is still running after the exit event")
+    }
+} // <-- TestSupervisor
+
+// A server that takes more than 10 seconds to load still reports it
+func TestSupervisorSlowStartup(t *testing.T) {
+    if testing.Short() {
+        t.Skip("the fake server takes 10 seconds to load")
+    }
+    bin := build_fakemc(t)
+
+    handle := MakeHandle(Config{
+        Cmdline: []string{ bin, "-startup-delay", "10s" },
+    })
+    if err := handle.Start(); err != nil {
+        t.Fatalf("Start: %v", err)
+    }
+    defer handle.Kill()
+
+    wait_event(t, &handle, "the server to load", func(event any) bool {
+        _, is_loaded := event.(OutputEventServerLoaded)
+        return is_loaded
+    })
+    if state := handle.State(); state != HS_RUNNING {
+        t.Errorf("state after loading = %d, want HS_RUNNING", state)
+    }
+
+    handle.In() <- InputEventCommand{ Command: "/stop" }
+    wait_event(t, &handle, "the server to exit", func(event any) bool {
+        _, is_exit := event.(OutputEventExit)
+        return is_exit
+    })
+} // <-- TestSupervisorSlowStartup
+
+func TestStartFailure(t *testing.T) {
+    for _, cmdline := range [][]string{ nil, { "" } } {
+        handle := MakeHandle(Config{ Cmdline: cmdline })
+        err := handle.Start()
+        if srv_err, ok := err.(*Error); !ok || srv_err.Type != ERR_CMDLINE {
+            t.Errorf("Start with the command line %q = %v, want ERR_CMDLINE", cmdline, err)
+        }
+    }
+
+    missing := filepath.Join(t.TempDir(), "no-such-server")
+    handle := MakeHandle(Config{ Cmdline: []string{ missing } })
+    for range 2 {
+        err := handle.Start()
+        if err == nil {
+            t.Fatal("Start with a missing binary succeeded")
+        }
+        if srv_err, ok := err.(*Error); ok && srv_err.Type == ERR_RUNNING {
+            t.Fatal("a failed start left the server running")
+        }
+        if handle.IsRunning() {
+            t.Error("the server is running after a failed start")
+        }
+    }
+} // <-- TestStartFailure
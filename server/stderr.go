@@ -0,0 +1,49 @@
+// stderr.go
+// Make sense of what the JVM writes to stderr
+package server
+
+import (
+    "regexp"
+    "strings"
+)
+
+var (
+    // Java stack trace lines that continue an exception
+    trace_line_r = regexp.MustCompile(
+        `^(\s+at |\s+\.\.\. [0-9]+ (more|common frames omitted)|Caused by: |\s+Suppressed: |\s+Caused by: )`,
+    )
+    // First line of an exception or a JVM error
+    exception_r = regexp.MustCompile(
+        `^(Exception in thread "[^"]*" |Caused by: )?(([A-Za-z_$][A-Za-z0-9_$]*\.)+[A-Za-z_$][A-Za-z0-9_$]*(Exception|Error|Throwable))(: .*)?$|^Error: |^Error occurred during initialization of VM`,
+    )
+)
+
+// Check if the line continues a stack trace
+func is_trace_continuation(_ string, line string) bool {
+    return trace_line_r.MatchString(line)
+} // <-- is_trace_continuation(record, line)
+
+// Split the record into the first line and the rest
+func split_record(record string) (string, []string) {
+    lines := strings.Split(strings.TrimRight(record, "\r\n"), "\n")
+    for i := range lines {
+        lines[i] = strings.TrimRight(lines[i], "\r")
+    }
+    return lines[0], lines[1:]
+} // <-- split_record(record)
+
+// Make an error event from a stderr record, nil if the record does not look
+// like an error
+func parse_stderr(record string) any {
+    first, rest := split_record(record)
+    if !exception_r.MatchString(first) {
+        return nil
+    }
+
+    return OutputEventServerError{
+        Stderr:  true,
+        Level:   "ERROR",
+        Message: first,
+        Trace:   rest,
+    }
+} // <-- parse_stderr(record)
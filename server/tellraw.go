@@ -0,0 +1,113 @@
+// tellraw.go
+// JSON text components for the /tellraw command
+package server
+
+import (
+    "strings"
+)
+
+// Length of the replied message's preview shown in chat, in characters
+const REPLY_PREVIEW = 32
+
+// Length of the replied message shown in the preview's tooltip
+const REPLY_HOVER = 256
+
+// tellraw command
+type tellraw_cmd struct {
+    Text          string         `json:"text"`
+    Color         string         `json:"color"`
+    Bold          bool           `json:"bold,omitempty"`
+    Italic        bool           `json:"italic,omitempty"`
+    Underlined    bool           `json:"underlined,omitempty"`
+    Strikethrough bool           `json:"strikethrough,omitempty"`
+    // Scrambled text
+    Obfuscated    bool           `json:"obfuscated,omitempty"`
+    ClickEvent    *tellraw_click `json:"clickEvent,omitempty"`
+    HoverEvent    *tellraw_hover `json:"hoverEvent,omitempty"`
+    // Child components, inherit the style of this one
+    Extra         []tellraw_cmd  `json:"extra,omitempty"`
+} // <-- struct tellraw_cmd
+
+// Action performed when the component is clicked
+type tellraw_click struct {
+    // open_url, suggest_command, copy_to_clipboard...
+    Action string `json:"action"`
+    Value  string `json:"value"`
+} // <-- struct tellraw_click
+
+// Tooltip shown when the component is hovered
+type tellraw_hover struct {
+    // show_text
+    Action   string        `json:"action"`
+    Contents []tellraw_cmd `json:"contents"`
+} // <-- struct tellraw_hover
+
+// Hover tooltip with plain text
+func hover_text(text string) *tellraw_hover {
+    return &tellraw_hover{
+        Action:   "show_text",
+        Contents: []tellraw_cmd{ { Text: text, Color: "gray" } },
+    }
+} // <-- hover_text(text)
+
+// "[photo]"-like placeholder for a media message, opens `link` when clicked
+// (if not empty)
+func media_tellraw(media string, link string) tellraw_cmd {
+    ret := tellraw_cmd{ Text: "[" + media + "]", Color: "aqua" }
+    if len(link) != 0 {
+        ret.ClickEvent = &tellraw_click{ Action: "open_url", Value: link }
+        ret.HoverEvent = hover_text("Click to open")
+    }
+    return ret
+} // <-- media_tellraw(media, link)
+
+// Put spaces between the components
+func spaced(cmds []tellraw_cmd) []tellraw_cmd {
+    var ret []tellraw_cmd
+    for i, cmd := range cmds {
+        if i != 0 {
+            ret = append(ret, tellraw_cmd{ Text: " ", Color: "white" })
+        }
+        ret = append(ret, cmd)
+    }
+    return ret
+} // <-- spaced(cmds)
+
+// Cut the text to `limit` characters, marking the cut with an ellipsis
+func shorten(text string, limit int) string {
+    runes := []rune(text)
+    if len(runes) <= limit {
+        return text
+    }
+    return strings.TrimRight(string(runes[:limit - 1]), " ") + "…"
+} // <-- shorten(text, limit)
+
+// Dimmed "[↪ Steve: first words…]" segment for a reply to `author`'s message
+// (`author` may be empty if the name is a part of `text`). The full text is
+// shown in the tooltip
+func reply_tellraw(author string, text string) tellraw_cmd {
+    preview, _, _ := strings.Cut(text, "\n")
+    if len(author) != 0 {
+        preview = author + ": " + preview
+    }
+    hover := "Replying to"
+    if len(author) != 0 {
+        hover += " " + author
+    }
+
+    return tellraw_cmd{
+        Text:       "[↪ " + shorten(preview, REPLY_PREVIEW) + "]",
+        Color:      "dark_gray",
+        HoverEvent: hover_text(hover + ":\n" + shorten(text, REPLY_HOVER)),
+    }
+} // <-- reply_tellraw(author, text)
+
+// "(edited)" note for an edited message, shows the `original` text (if
+// known) when hovered
+func edited_tellraw(original string) tellraw_cmd {
+    ret := tellraw_cmd{ Text: "(edited)", Color: "dark_gray" }
+    if len(original) != 0 {
+        ret.HoverEvent = hover_text("Was:\n" + shorten(original, REPLY_HOVER))
+    }
+    return ret
+} // <-- edited_tellraw(original)
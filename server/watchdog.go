@@ -0,0 +1,111 @@
+// watchdog.go
+// Detect a hung server by probing its console
+package server
+
+import (
+    "os/exec"
+    "time"
+)
+
+// Defaults for WatchdogConfig
+const (
+    DEFAULT_PROBE_TIMEOUT = 10
+    DEFAULT_MAX_MISSED    = 3
+)
+
+// Hang watchdog config. The watchdog periodically sends /list to the server
+// console and expects the player list in response
+type WatchdogConfig struct {
+    // Seconds between the probes. 0 disables the watchdog
+    Interval  uint `json:"interval,omitempty"`
+    // Seconds to wait for the response. 0 for the default
+    Timeout   uint `json:"timeout,omitempty"`
+    // Missed probes in a row after which the server is considered hung. 0
+    // for the default
+    MaxMissed uint `json:"max_missed,omitempty"`
+    // Kill the hung server so that it is restarted
+    Restart   bool `json:"restart,omitempty"`
+} // <-- struct WatchdogConfig
+
+// Ping the server console until the process exits
+func (self *Handle) watchdog(cmd *exec.Cmd, exited <-chan struct{}) {
+    missed := uint(0)
+    hung   := false
+
+    for {
+        config := self.cfg().Watchdog
+        interval := time.Duration(config.Interval) * time.Second
+        if config.Interval == 0 {
+            // Disabled, but may be enabled by a config reload
+            interval = time.Minute
+        }
+
+        select {
+        case <-exited:
+            return
+        case <-time.After(interval):
+        }
+
+        config = self.cfg().Watchdog
+        if config.Interval == 0 || self.state.Load() != HS_RUNNING {
+            missed = 0
+            continue
+        }
+
+        timeout := time.Duration(config.Timeout) * time.Second
+        if config.Timeout == 0 {
+            timeout = DEFAULT_PROBE_TIMEOUT * time.Second
+        }
+        max_missed := config.MaxMissed
+        if max_missed == 0 {
+            max_missed = DEFAULT_MAX_MISSED
+        }
+
+        // Forget the responses to the earlier /list commands
+        select {
+        case <-self.probe_ack:
+        default:
+        }
+
+        // A hung server may not even take the command: the stdin handler
+        // blocks on the full pipe. That counts as a missed probe too
+        deadline := time.After(timeout)
+        select {
+        case self.in <- input_event_probe{}:
+            select {
+            case <-exited:
+                return
+            case <-self.probe_ack:
+                missed = 0
+                if hung {
+                    hung = false
+                    self.out <- OutputEventServerRecovered{}
+                }
+                continue
+            case <-deadline:
+            }
+        case <-exited:
+            return
+        case <-deadline:
+        }
+
+        missed++
+        if missed < max_missed || hung {
+            continue
+        }
+
+        hung = true
+        self.out <- OutputEventServerHung{
+            Missed:  missed,
+            Restart: config.Restart,
+        }
+        // A single step, so that a graceful stop started meanwhile is not
+        // cut short
+        if config.Restart && self.state.CompareAndSwap(HS_RUNNING, HS_KILLING) {
+            // TryRestart is left as is, so the server comes back up
+            if err := kill_process(cmd); err != nil {
+                self.out <- OutputEventError{ err }
+            }
+        }
+    }
+} // <-- Handle::watchdog(cmd, exited)
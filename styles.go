@@ -0,0 +1,46 @@
+// styles.go
+// Telegram message formatting in Minecraft
+package main
+
+import (
+    "strings"
+
+    "github.com/gregthemadmonk/mctg-server-bot/server"
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+)
+
+// Minecraft chat formatting for the Telegram message entities. Entities
+// without a Minecraft counterpart are skipped
+func mc_styles(text string, entities []tg_api.MessageEntity) []server.Style {
+    var ret []server.Style
+    for _, entity := range entities {
+        start, end := tg_api.ByteRange(text, entity)
+        style := server.Style{ Start: start, End: end }
+        switch entity.Type {
+        case tg_api.ET_BOLD:
+            style.Bold = true
+        case tg_api.ET_ITALIC, tg_api.ET_BLOCKQUOTE:
+            style.Italic = true
+        case tg_api.ET_UNDERLINE:
+            style.Underlined = true
+        case tg_api.ET_STRIKETHROUGH:
+            style.Strikethrough = true
+        case tg_api.ET_SPOILER:
+            style.Spoiler = true
+        case tg_api.ET_CODE, tg_api.ET_PRE:
+            style.Code = true
+        case tg_api.ET_URL:
+            // Telegram also detects links without the scheme
+            style.Url = text[start:end]
+            if !strings.Contains(style.Url, "://") {
+                style.Url = "https://" + style.Url
+            }
+        case tg_api.ET_TEXT_LINK:
+            style.Url = entity.Url
+        default:
+            continue
+        }
+        ret = append(ret, style)
+    }
+    return ret
+} // <-- mc_styles(text, entities)
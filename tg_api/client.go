@@ -0,0 +1,231 @@
+// client.go
+// Telegram bot API client
+package tg_api
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime/multipart"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Default time limit for a single API request
+const DEFAULT_TIMEOUT = 30 * time.Second
+
+// Telegram bot API client. Holds the bot token and a shared HTTP client, so
+// connections are reused between requests
+type Client struct {
+    // Bot API token
+    token    string
+    // API server URL, e.g. https://api.telegram.org
+    base_url string
+    // Time limit for a single request (on top of the long polling timeout)
+    timeout  time.Duration
+    // HTTP client shared by all requests
+    http     *http.Client
+} // <-- struct Client
+
+// Client settings. The zero value talks to the official API server directly
+// (or through the proxy from the HTTP_PROXY/HTTPS_PROXY environment)
+type ClientOptions struct {
+    // API server URL. Empty for API_BASE
+    BaseUrl string
+    // Proxy URL: http://, https://, socks5:// or socks5h://
+    Proxy   string
+} // <-- struct ClientOptions
+
+// Create a client for the bot with the given token
+func MakeClient(token string, opts ClientOptions) (*Client, error) {
+    ret := Client{
+        token:    token,
+        base_url: API_BASE,
+        timeout:  DEFAULT_TIMEOUT,
+    }
+
+    if len(opts.BaseUrl) != 0 {
+        if u, err := url.Parse(opts.BaseUrl); err != nil {
+            return nil, fmt.Errorf("bad API URL: %w", err)
+        } else if u.Scheme != "http" && u.Scheme != "https" {
+            return nil, fmt.Errorf("bad API URL: %s", opts.BaseUrl)
+        }
+        ret.base_url = strings.TrimRight(opts.BaseUrl, "/")
+    }
+
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    if len(opts.Proxy) != 0 {
+        proxy, err := url.Parse(opts.Proxy)
+        if err != nil {
+            return nil, fmt.Errorf("bad proxy URL: %w", err)
+        }
+        switch proxy.Scheme {
+        case "http", "https", "socks5", "socks5h":
+        default:
+            return nil, fmt.Errorf(
+                "unsupported proxy scheme \"%s\"", proxy.Scheme,
+            )
+        }
+        transport.Proxy = http.ProxyURL(proxy)
+    }
+    ret.http = &http.Client{ Transport: transport }
+
+    return &ret, nil
+} // <-- MakeClient(token, opts)
+
+// Get the URL of the API method
+func (self *Client) Uri(method string) string {
+    return fmt.Sprintf("%s/bot%s/%s", self.base_url, self.token, method)
+} // <-- Client::Uri(method)
+
+// Hide the bot token in the request URL the error mentions, so that it
+// doesn't end up in the logs
+func (self *Client) redact(err error) error {
+    var url_err *url.Error
+    if errors.As(err, &url_err) && len(self.token) != 0 {
+        url_err.URL = strings.ReplaceAll(url_err.URL, self.token, "<token>")
+    }
+    return err
+} // <-- Client::redact(err)
+
+// Call the API method with JSON-encoded `params` (nil for no parameters) and
+// deserialize the result into `result`. `extra` extends the request time
+// limit, e.g. for long polling
+func (self *Client) call(
+    ctx context.Context,
+    method string,
+    params any,
+    result any,
+    extra time.Duration,
+) error {
+    ctx, cancel := context.WithTimeout(ctx, self.timeout + extra)
+    defer cancel()
+
+    var body io.Reader
+    if params != nil {
+        if js, js_err := json.Marshal(params); js_err == nil {
+            body = bytes.NewReader(js)
+        } else {
+            return js_err
+        }
+    }
+
+    req, req_err := http.NewRequestWithContext(
+        ctx, http.MethodPost, self.Uri(method), body,
+    )
+    if req_err != nil {
+        return self.redact(req_err)
+    }
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+
+    return self.do(req, method, result)
+} // <-- Client::call(ctx, method, params, result, extra)
+
+// Call the API method with a multipart/form-data body: `fields` are sent as
+// is, `files` maps the field names to the paths of the files to upload. The
+// files are streamed from disk, so large uploads are never held in memory
+func (self *Client) call_multipart(
+    ctx context.Context,
+    method string,
+    fields map[string]string,
+    files map[string]string,
+    result any,
+) error {
+    // Open everything first so that a missing file is reported before the
+    // request is sent
+    opened := make(map[string]*os.File, len(files))
+    defer func() {
+        for _, file := range opened {
+            file.Close()
+        }
+    }()
+    for name, path := range files {
+        file, file_err := os.Open(path)
+        if file_err != nil {
+            return file_err
+        }
+        opened[name] = file
+    }
+
+    pr, pw := io.Pipe()
+    form := multipart.NewWriter(pw)
+    go func() {
+        pw.CloseWithError(write_form(form, fields, opened))
+    }()
+    // Unblocks the writer if the request fails before reading the body
+    defer pr.Close()
+
+    // Uploads take longer than the usual requests
+    ctx, cancel := context.WithTimeout(ctx, 4 * self.timeout)
+    defer cancel()
+
+    req, req_err := http.NewRequestWithContext(
+        ctx, http.MethodPost, self.Uri(method), pr,
+    )
+    if req_err != nil {
+        return self.redact(req_err)
+    }
+    req.Header.Set("Content-Type", form.FormDataContentType())
+
+    return self.do(req, method, result)
+} // <-- Client::call_multipart(ctx, method, fields, files, result)
+
+// Write the multipart body: the plain fields first, then the files
+func write_form(
+    form *multipart.Writer, fields map[string]string, files map[string]*os.File,
+) error {
+    for name, value := range fields {
+        if err := form.WriteField(name, value); err != nil {
+            return err
+        }
+    }
+    for name, file := range files {
+        part, part_err := form.CreateFormFile(name, filepath.Base(file.Name()))
+        if part_err != nil {
+            return part_err
+        }
+        if _, err := io.Copy(part, file); err != nil {
+            return err
+        }
+    }
+    return form.Close()
+} // <-- write_form(form, fields, files)
+
+// Send the request and decode the response envelope
+func (self *Client) do(req *http.Request, method string, result any) error {
+    res, err := self.http.Do(req)
+    if err != nil {
+        return self.redact(err)
+    }
+    defer res.Body.Close()
+
+    data, read_err := io.ReadAll(res.Body)
+    if read_err != nil {
+        return read_err
+    }
+
+    var envelope ExchangeResult[json.RawMessage]
+    if js_err := json.Unmarshal(data, &envelope); js_err != nil {
+        return fmt.Errorf(
+            "%s: bad response (HTTP %d): %w", method, res.StatusCode, js_err,
+        )
+    }
+
+    if !envelope.Ok {
+        return envelope.AsError(method)
+    }
+
+    if result == nil {
+        return nil
+    }
+    return json.Unmarshal(envelope.Result, result)
+} // <-- Client::do(req, method, result)
@@ -0,0 +1,96 @@
+// entities.go
+// Message entities (mentions, formatting, links...)
+package tg_api
+
+import (
+    "unicode/utf16"
+)
+
+// Entity types
+const (
+    ET_MENTION       = "mention"
+    ET_TEXT_MENTION  = "text_mention"
+    ET_URL           = "url"
+    ET_TEXT_LINK     = "text_link"
+    ET_BOLD          = "bold"
+    ET_ITALIC        = "italic"
+    ET_UNDERLINE     = "underline"
+    ET_STRIKETHROUGH = "strikethrough"
+    ET_SPOILER       = "spoiler"
+    ET_CODE          = "code"
+    ET_PRE           = "pre"
+    ET_BLOCKQUOTE    = "blockquote"
+)
+
+// Special part of a message's text. Offsets and lengths are in UTF-16 code
+// units, use UTF16Len to compute them
+type MessageEntity struct {
+    Type     string `json:"type"`
+    Offset   int    `json:"offset"`
+    Length   int    `json:"length"`
+    // For text_link
+    Url      string `json:"url,omitempty"`
+    // For text_mention
+    User     *User  `json:"user,omitempty"`
+    // For pre, the programming language of the code
+    Language string `json:"language,omitempty"`
+} // <-- struct MessageEntity
+
+// Length of the string in UTF-16 code units, as Telegram counts it
+func UTF16Len(s string) int {
+    ret := 0
+    for _, r := range s {
+        n := utf16.RuneLen(r)
+        if n < 0 {
+            n = 1 // Invalid runes are replaced with U+FFFD
+        }
+        ret += n
+    }
+    return ret
+} // <-- UTF16Len(s)
+
+// Byte offsets in `text` of the part `entity` applies to
+func ByteRange(text string, entity MessageEntity) (int, int) {
+    start, end := len(text), len(text)
+    units := 0
+    for i, r := range text {
+        if units == entity.Offset {
+            start = i
+        }
+        if units == entity.Offset + entity.Length {
+            end = i
+            break
+        }
+        n := utf16.RuneLen(r)
+        if n < 0 {
+            n = 1
+        }
+        units += n
+    }
+    if start > end {
+        start = end
+    }
+    return start, end
+} // <-- ByteRange(text, entity)
+
+// Entities for the substring text[start:end] (byte offsets): the ones that
+// overlap it, cut to it and with offsets relative to it
+func SliceEntities(
+    text string, entities []MessageEntity, start int, end int,
+) []MessageEntity {
+    base := UTF16Len(text[:start])
+    limit := base + UTF16Len(text[start:end])
+
+    var ret []MessageEntity
+    for _, entity := range entities {
+        from := max(entity.Offset, base)
+        to := min(entity.Offset + entity.Length, limit)
+        if from >= to {
+            continue
+        }
+        entity.Offset = from - base
+        entity.Length = to - from
+        ret = append(ret, entity)
+    }
+    return ret
+} // <-- SliceEntities(text, entities, start, end)
@@ -0,0 +1,97 @@
+package tg_api
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestUTF16Len(t *testing.T) {
+    tests := []struct {
+        name string
+        text string
+        want int
+    }{
+        { "empty", "", 0 },
+        { "ascii", "hello", 5 },
+        { "cyrillic", "привет", 6 },
+        { "surrogate pair", "a😀b", 4 },
+        { "invalid utf-8", "a\xffb", 3 },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            if got := UTF16Len(test.text); got != test.want {
+                t.Errorf("UTF16Len(%q) = %d, want %d", test.text, got, test.want)
+            }
+        })
+    }
+} // <-- TestUTF16Len
+
+func TestByteRange(t *testing.T) {
+    tests := []struct {
+        name   string
+        text   string
+        offset int
+        length int
+        want   string
+    }{
+        { "ascii", "hello world", 6, 5, "world" },
+        { "whole text", "hello", 0, 5, "hello" },
+        { "after emoji", "😀 @Steve hi", 3, 6, "@Steve" },
+        { "emoji itself", "a😀b", 1, 2, "😀" },
+        { "cyrillic", "привет мир", 7, 3, "мир" },
+        { "past the end", "hello", 3, 10, "lo" },
+        { "empty", "hello", 2, 0, "" },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            start, end := ByteRange(
+                test.text,
+                MessageEntity{ Offset: test.offset, Length: test.length },
+            )
+            if got := test.text[start:end]; got != test.want {
+                t.Errorf("ByteRange = %q, want %q", got, test.want)
+            }
+        })
+    }
+} // <-- TestByteRange
+
+func TestSliceEntities(t *testing.T) {
+    // "😀 bold @Steve tail": the emoji is 2 units, 4 bytes
+    text := "😀 bold @Steve tail"
+    bold := MessageEntity{ Type: ET_BOLD, Offset: 3, Length: 4 }
+    mention := MessageEntity{ Type: ET_MENTION, Offset: 8, Length: 6 }
+    entities := []MessageEntity{ bold, mention }
+
+    tests := []struct {
+        name  string
+        start int
+        end   int
+        want  []MessageEntity
+    }{
+        { "whole text", 0, len(text), entities },
+        {
+            "after the emoji", 5, len(text),
+            []MessageEntity{
+                { Type: ET_BOLD, Offset: 0, Length: 4 },
+                { Type: ET_MENTION, Offset: 5, Length: 6 },
+            },
+        },
+        {
+            "cuts the mention", 7, 12,
+            []MessageEntity{
+                { Type: ET_BOLD, Offset: 0, Length: 2 },
+                { Type: ET_MENTION, Offset: 3, Length: 2 },
+            },
+        },
+        { "no overlap", len(text) - 4, len(text), nil },
+        { "empty", 5, 5, nil },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            got := SliceEntities(text, entities, test.start, test.end)
+            if !reflect.DeepEqual(got, test.want) {
+                t.Errorf("SliceEntities = %+v, want %+v", got, test.want)
+            }
+        })
+    }
+} // <-- TestSliceEntities
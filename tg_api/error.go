@@ -4,10 +4,39 @@ import (
     "fmt"
 )
 
-func (self *ExchangeResult[T]) Error() string {
+// Error returned by the Telegram bot API
+type Error struct {
+    // API method that failed
+    Method          string
+    // Error code, mostly mirrors HTTP status codes
+    ErrorCode       int
+    // Human-readable description
+    Description     string
+    // Seconds to wait before repeating the request (flood control), or 0
+    RetryAfter      int
+    // The group has been migrated to a supergroup with this ID, or 0
+    MigrateToChatId int
+} // <-- struct Error
+
+func (self *Error) Error() string {
     return fmt.Sprintf(
-        "Telegram API error code %d: %s",
+        "Telegram API error code %d in %s: %s",
         self.ErrorCode,
+        self.Method,
         self.Description,
     )
-} // <-- *ExchangeResult[T]::Error()
+} // <-- *Error::Error()
+
+// Convert the unsuccessful result into an error
+func (self *ExchangeResult[T]) AsError(method string) *Error {
+    ret := Error{
+        Method:      method,
+        ErrorCode:   self.ErrorCode,
+        Description: self.Description,
+    }
+    if self.Parameters != nil {
+        ret.RetryAfter      = self.Parameters.RetryAfter
+        ret.MigrateToChatId = self.Parameters.MigrateToChatId
+    }
+    return &ret
+} // <-- *ExchangeResult[T]::AsError(method)
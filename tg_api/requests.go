@@ -1,70 +1,102 @@
+// requests.go
+// Typed wrappers for the Telegram bot API methods
 package tg_api
 
 import (
-    "bytes"
-    "encoding/json"
-    "io"
-    "net/http"
+    "context"
+    "strconv"
+    "time"
 )
 
-// Send a GET request to the endpoint
-func Exchange(endpoint string) (*[]byte, error) {
-    if res, e := http.Get(endpoint); e == nil {
-        if body, err_b := io.ReadAll(res.Body); err_b == nil {
-            return &body, nil
-        } else {
-            return nil, err_b
-        }
-    } else {
-        return nil, e
+// Get the information about the bot
+func (self *Client) GetMe(ctx context.Context) (*GetMe, error) {
+    var ret GetMe
+    if err := self.call(ctx, "getMe", nil, &ret, 0); err != nil {
+        return nil, err
+    }
+    return &ret, nil
+} // <-- Client::GetMe(ctx)
+
+// Receive incoming updates. With `params.Timeout` set, the request blocks
+// until an update arrives or the timeout expires (long polling)
+func (self *Client) GetUpdates(
+    ctx context.Context, params GetUpdates,
+) ([]Update, error) {
+    var ret []Update
+    poll := time.Duration(params.Timeout) * time.Second
+    if err := self.call(ctx, "getUpdates", params, &ret, poll); err != nil {
+        return nil, err
+    }
+    return ret, nil
+} // <-- Client::GetUpdates(ctx, params)
+
+// Send a text message
+func (self *Client) SendMessage(
+    ctx context.Context, params SendMessage,
+) (*Message, error) {
+    var ret Message
+    if err := self.call(ctx, "sendMessage", params, &ret, 0); err != nil {
+        return nil, err
     }
-} // <-- Exchange(endpoint)
+    return &ret, nil
+} // <-- Client::SendMessage(ctx, params)
 
-// Send a POST request to the endpoint with JSON data from `params`
-func ExchangeWith[P any](endpoint string, params P) (*[]byte, error) {
-    if body, js_e := json.Marshal(params); js_e == nil {
-        // log.Println("Sending", string(body))
-        rqb := bytes.NewBuffer(body)
-        if res, e := http.Post(endpoint, "application/json", rqb); e == nil {
-            if resp_body, err_b := io.ReadAll(res.Body); err_b == nil {
-                return &resp_body, nil
-            } else {
-                return nil, err_b
-            }
-        } else {
-            return nil, e
-        }
-    } else {
-        return nil, js_e
+// Edit the text of a message
+func (self *Client) EditMessageText(
+    ctx context.Context, params EditMessageText,
+) (*Message, error) {
+    var ret Message
+    if err := self.call(ctx, "editMessageText", params, &ret, 0); err != nil {
+        return nil, err
     }
-} // <-- ExchangeWith[P](endpoint, params)
+    return &ret, nil
+} // <-- Client::EditMessageText(ctx, params)
+
+// Delete a message
+func (self *Client) DeleteMessage(
+    ctx context.Context, params DeleteMessage,
+) error {
+    return self.call(ctx, "deleteMessage", params, nil, 0)
+} // <-- Client::DeleteMessage(ctx, params)
 
-// Same as `exchange`, but stores deserializes the response into T
-func ExchangeInto[T any](endpoint string) (*ExchangeResult[T], error) {
-    if res, err := Exchange(endpoint); err == nil {
-        var ret ExchangeResult[T]
-        // log.Println("Deserializing ", string(*res))
-        if js_err := json.Unmarshal(*res, &ret); js_err != nil {
-            return nil, js_err
-        }
-        return &ret, nil
-    } else {
+// Fields shared by the file uploads
+func upload_fields(chat_id int, caption, parse_mode string) map[string]string {
+    ret := map[string]string{ "chat_id": strconv.Itoa(chat_id) }
+    if len(caption) != 0 {
+        ret["caption"] = caption
+    }
+    if len(parse_mode) != 0 {
+        ret["parse_mode"] = parse_mode
+    }
+    return ret
+} // <-- upload_fields(chat_id, caption, parse_mode)
+
+// Upload a file as a document
+func (self *Client) SendDocument(
+    ctx context.Context, params SendDocument,
+) (*Message, error) {
+    fields := upload_fields(params.ChatId, params.Caption, params.ParseMode)
+    files := map[string]string{ "document": params.Document }
+
+    var ret Message
+    err := self.call_multipart(ctx, "sendDocument", fields, files, &ret)
+    if err != nil {
         return nil, err
     }
-} // <-- ExchangeInto[T](endpoint)
+    return &ret, nil
+} // <-- Client::SendDocument(ctx, params)
+
+// Upload an image as a photo
+func (self *Client) SendPhoto(
+    ctx context.Context, params SendPhoto,
+) (*Message, error) {
+    fields := upload_fields(params.ChatId, params.Caption, params.ParseMode)
+    files := map[string]string{ "photo": params.Photo }
 
-// Same as `exchange_with`, but stores deserializes the response into T
-func ExchangeIntoWith[T any, P any](
-    endpoint string, params P,
-) (*ExchangeResult[T], error) {
-    if res, err := ExchangeWith(endpoint, params); err == nil {
-        var ret ExchangeResult[T]
-        // log.Println("Deserializing ", string(*res))
-        if js_err := json.Unmarshal(*res, &ret); js_err != nil {
-            return nil, js_err
-        }
-        return &ret, nil
-    } else {
+    var ret Message
+    err := self.call_multipart(ctx, "sendPhoto", fields, files, &ret)
+    if err != nil {
         return nil, err
     }
-} // <-- ExchangeIntoWith[T, P](endpoint, params)
+    return &ret, nil
+} // <-- Client::SendPhoto(ctx, params)
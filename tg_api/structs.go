@@ -8,8 +8,15 @@ type ExchangeResult[T any] struct {
     ErrorCode   int    `json:"error_code"`
     Description string `json:"description"`
     Result      T      `json:"result"`
+    // Extra information on why the request failed
+    Parameters  *ResponseParameters `json:"parameters,omitempty"`
 } // <-- struct ExchangeResult[T]
 
+type ResponseParameters struct {
+    MigrateToChatId int `json:"migrate_to_chat_id"`
+    RetryAfter      int `json:"retry_after"`
+} // <-- struct ResponseParameters
+
 type GetMe struct {
     Id                      int    `json:"id"`
     IsBot                   bool   `json:"is_bot"`
@@ -23,18 +30,126 @@ type GetMe struct {
 } // <-- struct GetMe
 
 type User struct {
-    Username string `json:"username"`
+    Id        int    `json:"id"`
+    IsBot     bool   `json:"is_bot"`
+    FirstName string `json:"first_name"`
+    Username  string `json:"username"`
 } // <-- struct User
 
 type Chat struct {
-    Id int `json:"id"`
+    Id       int    `json:"id"`
+    // Set for public chats
+    Username string `json:"username,omitempty"`
 } // <-- struct Chat
 
+type Document struct {
+    FileId       string `json:"file_id"`
+    FileUniqueId string `json:"file_unique_id"`
+    FileName     string `json:"file_name,omitempty"`
+    MimeType     string `json:"mime_type,omitempty"`
+    FileSize     int    `json:"file_size,omitempty"`
+} // <-- struct Document
+
+// One size of a photo. Telegram sends several of them, smallest first
+type PhotoSize struct {
+    FileId       string `json:"file_id"`
+    FileUniqueId string `json:"file_unique_id"`
+    Width        int    `json:"width"`
+    Height       int    `json:"height"`
+    FileSize     int    `json:"file_size,omitempty"`
+} // <-- struct PhotoSize
+
+type Sticker struct {
+    FileId       string `json:"file_id"`
+    FileUniqueId string `json:"file_unique_id"`
+    Width        int    `json:"width"`
+    Height       int    `json:"height"`
+    IsAnimated   bool   `json:"is_animated"`
+    IsVideo      bool   `json:"is_video"`
+    // Emoji the sticker corresponds to
+    Emoji        string `json:"emoji,omitempty"`
+    SetName      string `json:"set_name,omitempty"`
+} // <-- struct Sticker
+
+// GIF or an H.264 video without sound
+type Animation struct {
+    FileId       string `json:"file_id"`
+    FileUniqueId string `json:"file_unique_id"`
+    Width        int    `json:"width"`
+    Height       int    `json:"height"`
+    // In seconds
+    Duration     int    `json:"duration"`
+    FileName     string `json:"file_name,omitempty"`
+    MimeType     string `json:"mime_type,omitempty"`
+    FileSize     int    `json:"file_size,omitempty"`
+} // <-- struct Animation
+
+type Voice struct {
+    FileId       string `json:"file_id"`
+    FileUniqueId string `json:"file_unique_id"`
+    // In seconds
+    Duration     int    `json:"duration"`
+    MimeType     string `json:"mime_type,omitempty"`
+    FileSize     int    `json:"file_size,omitempty"`
+} // <-- struct Voice
+
+type PollOption struct {
+    Text       string `json:"text"`
+    VoterCount int    `json:"voter_count"`
+} // <-- struct PollOption
+
+type Poll struct {
+    Id       string       `json:"id"`
+    Question string       `json:"question"`
+    Options  []PollOption `json:"options"`
+    IsClosed bool         `json:"is_closed"`
+    // "regular" or "quiz"
+    Type     string       `json:"type"`
+} // <-- struct Poll
+
+type Location struct {
+    Latitude  float64 `json:"latitude"`
+    Longitude float64 `json:"longitude"`
+} // <-- struct Location
+
+// Part of the message a reply quotes
+type TextQuote struct {
+    Text     string `json:"text"`
+    // Position of the quote in the original message, in UTF-16 code units
+    Position int    `json:"position"`
+    // The quote was chosen by the user rather than added automatically
+    IsManual bool   `json:"is_manual,omitempty"`
+} // <-- struct TextQuote
+
+// Service message about a new forum topic
+type ForumTopicCreated struct {
+    Name string `json:"name"`
+} // <-- struct ForumTopicCreated
+
 type Message struct {
-    MessageId int    `json:"message_id"`
-    From      User   `json:"from"`
-    Text      string `json:"text"`
-    Chat      Chat   `json:"chat"`
+    MessageId       int                `json:"message_id"`
+    From            User               `json:"from"`
+    Text            string             `json:"text"`
+    // Mentions, formatting, links... in the text
+    Entities        []MessageEntity    `json:"entities,omitempty"`
+    Chat            Chat               `json:"chat"`
+    // Text of a media message
+    Caption         string             `json:"caption,omitempty"`
+    CaptionEntities []MessageEntity    `json:"caption_entities,omitempty"`
+    Document        *Document          `json:"document,omitempty"`
+    // Available sizes of the photo, smallest first
+    Photo           []PhotoSize        `json:"photo,omitempty"`
+    Sticker         *Sticker           `json:"sticker,omitempty"`
+    Animation       *Animation         `json:"animation,omitempty"`
+    Voice           *Voice             `json:"voice,omitempty"`
+    Poll            *Poll              `json:"poll,omitempty"`
+    Location        *Location          `json:"location,omitempty"`
+    // The message this one replies to
+    ReplyToMessage  *Message           `json:"reply_to_message,omitempty"`
+    // The part of the replied message quoted in this one
+    Quote           *TextQuote         `json:"quote,omitempty"`
+    // In forums, messages in a topic reply to the message creating it
+    TopicCreated    *ForumTopicCreated `json:"forum_topic_created,omitempty"`
 } // <-- struct Message
 
 type Update struct {
@@ -46,10 +161,19 @@ type Update struct {
     // TODO: Implement other fields as needed
 } // <-- struct Update
 
+type GetUpdates struct {
+    Offset         int      `json:"offset"`
+    // Long polling timeout in seconds
+    Timeout        int      `json:"timeout,omitempty"`
+    AllowedUpdates []string `json:"allowed_updates,omitempty"`
+} // <-- struct GetUpdates
+
 type SendMessage struct {
-    ChatId    int    `json:"chat_id"`
-    Text      string `json:"text"`
-    ParseMode string `json:"parse_mode,omitempty"`
+    ChatId    int             `json:"chat_id"`
+    Text      string          `json:"text"`
+    ParseMode string          `json:"parse_mode,omitempty"`
+    // Used instead of ParseMode
+    Entities  []MessageEntity `json:"entities,omitempty"`
 } // <-- struct SendMessage
 
 type EditMessageText struct {
@@ -58,3 +182,27 @@ type EditMessageText struct {
     Text      string `json:"text"`
     ParseMode string `json:"parse_mode,omitempty"`
 } // <-- struct EditMessageText
+
+type DeleteMessage struct {
+    ChatId    int `json:"chat_id"`
+    MessageId int `json:"message_id"`
+} // <-- struct DeleteMessage
+
+// Upload a file from disk as a document
+type SendDocument struct {
+    ChatId    int
+    // Path to the file to upload
+    Document  string
+    Caption   string
+    ParseMode string
+} // <-- struct SendDocument
+
+// Upload an image from disk as a photo. Telegram recompresses it, use
+// SendDocument to send the file as is
+type SendPhoto struct {
+    ChatId    int
+    // Path to the file to upload
+    Photo     string
+    Caption   string
+    ParseMode string
+} // <-- struct SendPhoto
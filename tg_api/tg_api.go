@@ -1,7 +1,10 @@
 package tg_api
 
-// Telegram bot API URL base
-const API_BASE = "https://api.telegram.org/bot"
+// Official Telegram bot API server
+const API_BASE = "https://api.telegram.org"
 
 // Markdown parse mode
 const PM_MARKDOWN = "MarkdownV2"
+
+// HTML parse mode
+const PM_HTML = "HTML"
@@ -0,0 +1,597 @@
+// tgtest.go
+// In-memory fake of the Telegram bot API server for offline tests.
+// Point bot.Config.ApiUrl (or tg_api.ClientOptions.BaseUrl) at Server.Url()
+package tgtest
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+)
+
+// A request the bot has made
+type Call struct {
+    Method string
+    Params json.RawMessage
+} // <-- struct Call
+
+// Fake Telegram bot API server
+type Server struct {
+    mu       sync.Mutex
+    srv      *httptest.Server
+    token    string
+    // The bot's own account
+    Me       tg_api.GetMe
+
+    // Pending updates, not yet confirmed with getUpdates offset
+    updates     []tg_api.Update
+    next_update int
+    // Closed and replaced whenever a new update arrives
+    notify      chan struct{}
+
+    // All messages in all chats by ID
+    messages    map[int]*tg_api.Message
+    next_msg_id int
+    // Messages sent by the bot, in order
+    sent        []tg_api.Message
+    // Closed and replaced whenever the bot sends something
+    sent_notify chan struct{}
+    // All requests made by the bot
+    calls       []Call
+    // Uploaded files by file ID
+    files       map[string][]byte
+
+    // Errors to return from the next calls of the method
+    failures    map[string][]tg_api.Error
+    // Drop all connections, as if the server was unreachable
+    offline     bool
+} // <-- struct Server
+
+// Start the fake server accepting the given bot token
+func MakeServer(token string) *Server {
+    ret := Server{
+        token: token,
+        Me: tg_api.GetMe{
+            Id:        1,
+            IsBot:     true,
+            FirstName: "Test Bot",
+            Username:  "test_bot",
+        },
+        next_update: 1,
+        notify:      make(chan struct{}),
+        messages:    make(map[int]*tg_api.Message),
+        next_msg_id: 1,
+        sent_notify: make(chan struct{}),
+        files:       make(map[string][]byte),
+        failures:    make(map[string][]tg_api.Error),
+    }
+    ret.srv = httptest.NewServer(http.HandlerFunc(ret.serve))
+    return &ret
+} // <-- MakeServer(token)
+
+// URL to use as the bot API server URL
+func (self *Server) Url() string { return self.srv.URL }
+
+// Shut the server down
+func (self *Server) Close() { self.srv.Close() }
+
+// Make the server drop every connection (`true`) or serve again (`false`)
+func (self *Server) SetOffline(offline bool) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    self.offline = offline
+} // <-- Server::SetOffline(offline)
+
+// Make the next call of `method` fail with the error
+func (self *Server) Fail(method string, err tg_api.Error) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    self.failures[method] = append(self.failures[method], err)
+} // <-- Server::Fail(method, err)
+
+// Queue an arbitrary update for the bot. UpdateId is assigned by the server
+func (self *Server) InjectUpdate(update tg_api.Update) tg_api.Update {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    return self.push_update(update)
+} // <-- Server::InjectUpdate(update)
+
+// A user writes a message to the chat
+func (self *Server) InjectMessage(
+    chat_id int, from tg_api.User, text string,
+) tg_api.Message {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    msg := self.store(tg_api.Message{
+        From: from,
+        Text: text,
+        Chat: tg_api.Chat{ Id: chat_id },
+    })
+    self.push_update(tg_api.Update{ Message: &msg })
+    return msg
+} // <-- Server::InjectMessage(chat_id, from, text)
+
+// A user sends an arbitrary message (media, replies...). The message ID is
+// assigned by the server
+func (self *Server) Inject(msg tg_api.Message) tg_api.Message {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    msg = self.store(msg)
+    self.push_update(tg_api.Update{ Message: &msg })
+    return msg
+} // <-- Server::Inject(msg)
+
+// A user replies to the message with the given ID
+func (self *Server) InjectReply(
+    chat_id int, from tg_api.User, text string, reply_to int,
+) (tg_api.Message, error) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    replied, found := self.messages[reply_to]
+    if !found {
+        return tg_api.Message{}, fmt.Errorf("no message %d", reply_to)
+    }
+    reply := *replied
+    // Telegram doesn't nest the replies
+    reply.ReplyToMessage = nil
+
+    msg := self.store(tg_api.Message{
+        From:           from,
+        Text:           text,
+        Chat:           tg_api.Chat{ Id: chat_id },
+        ReplyToMessage: &reply,
+    })
+    self.push_update(tg_api.Update{ Message: &msg })
+    return msg, nil
+} // <-- Server::InjectReply(chat_id, from, text, reply_to)
+
+// A user edits their message
+func (self *Server) InjectEdit(message_id int, text string) (tg_api.Message, error) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    msg, found := self.messages[message_id]
+    if !found {
+        return tg_api.Message{}, fmt.Errorf("no message %d", message_id)
+    }
+    msg.Text = text
+
+    edited := *msg
+    self.push_update(tg_api.Update{ EditedMessage: &edited })
+    return edited, nil
+} // <-- Server::InjectEdit(message_id, text)
+
+// Look up a message by its ID
+func (self *Server) Message(message_id int) (tg_api.Message, bool) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    if msg, found := self.messages[message_id]; found {
+        return *msg, true
+    }
+    return tg_api.Message{}, false
+} // <-- Server::Message(message_id)
+
+// Messages sent by the bot so far
+func (self *Server) Sent() []tg_api.Message {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    return append([]tg_api.Message(nil), self.sent...)
+} // <-- Server::Sent()
+
+// Wait until the bot has sent at least `n` messages in total
+func (self *Server) WaitSent(
+    n int, timeout time.Duration,
+) ([]tg_api.Message, error) {
+    deadline := time.After(timeout)
+    for {
+        self.mu.Lock()
+        if len(self.sent) >= n {
+            ret := append([]tg_api.Message(nil), self.sent...)
+            self.mu.Unlock()
+            return ret, nil
+        }
+        notify := self.sent_notify
+        self.mu.Unlock()
+
+        select {
+        case <-notify:
+        case <-deadline:
+            return self.Sent(), fmt.Errorf(
+                "timed out waiting for %d sent messages", n,
+            )
+        }
+    }
+} // <-- Server::WaitSent(n, timeout)
+
+// All requests the bot has made so far
+func (self *Server) Calls() []Call {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    return append([]Call(nil), self.calls...)
+} // <-- Server::Calls()
+
+// Assign an ID to the message and remember it. Must be called with the mutex
+// locked
+func (self *Server) store(msg tg_api.Message) tg_api.Message {
+    msg.MessageId = self.next_msg_id
+    self.next_msg_id++
+    self.messages[msg.MessageId] = &msg
+    return msg
+} // <-- Server::store(msg)
+
+// The bot as the author of the messages it sends
+func (self *Server) bot_user() tg_api.User {
+    return tg_api.User{
+        Id:        self.Me.Id,
+        IsBot:     true,
+        FirstName: self.Me.FirstName,
+        Username:  self.Me.Username,
+    }
+} // <-- Server::bot_user()
+
+// Must be called with the mutex locked
+func (self *Server) push_update(update tg_api.Update) tg_api.Update {
+    update.UpdateId = self.next_update
+    self.next_update++
+    self.updates = append(self.updates, update)
+
+    close(self.notify)
+    self.notify = make(chan struct{})
+    return update
+} // <-- Server::push_update(update)
+
+// Must be called with the mutex locked
+func (self *Server) push_sent(msg tg_api.Message) {
+    self.sent = append(self.sent, msg)
+    close(self.sent_notify)
+    self.sent_notify = make(chan struct{})
+} // <-- Server::push_sent(msg)
+
+// Uploaded file
+type File struct {
+    Name string `json:"name"`
+    Data []byte `json:"data"`
+} // <-- struct File
+
+// Read the request parameters as JSON. Form parameters are converted to a
+// JSON object of strings, uploaded files to File objects
+func read_params(r *http.Request) ([]byte, error) {
+    media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    switch media {
+    case "multipart/form-data":
+        if err := r.ParseMultipartForm(64 << 20); err != nil {
+            return nil, err
+        }
+        params := make(map[string]any)
+        for name, values := range r.MultipartForm.Value {
+            params[name] = values[0]
+        }
+        for name, headers := range r.MultipartForm.File {
+            file, err := headers[0].Open()
+            if err != nil {
+                return nil, err
+            }
+            data, err := io.ReadAll(file)
+            file.Close()
+            if err != nil {
+                return nil, err
+            }
+            params[name] = File{ Name: headers[0].Filename, Data: data }
+        }
+        return json.Marshal(params)
+    case "application/x-www-form-urlencoded":
+        if err := r.ParseForm(); err != nil {
+            return nil, err
+        }
+        params := make(map[string]string)
+        for name, values := range r.PostForm {
+            params[name] = values[0]
+        }
+        return json.Marshal(params)
+    }
+
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        return nil, err
+    }
+    if len(body) == 0 {
+        body = []byte("{}")
+    }
+    return body, nil
+} // <-- read_params(r)
+
+// Write the API response envelope
+func reply(w http.ResponseWriter, result any, err *tg_api.Error) {
+    w.Header().Set("Content-Type", "application/json")
+
+    res := tg_api.ExchangeResult[any]{ Ok: err == nil, Result: result }
+    if err != nil {
+        res.ErrorCode   = err.ErrorCode
+        res.Description = err.Description
+        if err.RetryAfter != 0 || err.MigrateToChatId != 0 {
+            res.Parameters = &tg_api.ResponseParameters{
+                RetryAfter:      err.RetryAfter,
+                MigrateToChatId: err.MigrateToChatId,
+            }
+        }
+        w.WriteHeader(err.ErrorCode)
+    }
+
+    json.NewEncoder(w).Encode(res)
+} // <-- reply(w, result, err)
+
+func bad_request(description string) *tg_api.Error {
+    return &tg_api.Error{
+        ErrorCode:   400,
+        Description: "Bad Request: " + description,
+    }
+} // <-- bad_request(description)
+
+func (self *Server) serve(w http.ResponseWriter, r *http.Request) {
+    self.mu.Lock()
+    offline := self.offline
+    self.mu.Unlock()
+    if offline {
+        // Drop the connection without a response
+        if hj, ok := w.(http.Hijacker); ok {
+            if conn, _, err := hj.Hijack(); err == nil {
+                conn.Close()
+                return
+            }
+        }
+        w.WriteHeader(http.StatusBadGateway)
+        return
+    }
+
+    pfx := "/bot" + self.token + "/"
+    if !strings.HasPrefix(r.URL.Path, pfx) {
+        reply(w, nil, &tg_api.Error{ ErrorCode: 401, Description: "Unauthorized" })
+        return
+    }
+    method := r.URL.Path[len(pfx):]
+
+    body, read_err := read_params(r)
+    if read_err != nil {
+        reply(w, nil, bad_request(read_err.Error()))
+        return
+    }
+
+    self.mu.Lock()
+    self.calls = append(self.calls, Call{ Method: method, Params: body })
+    if fails := self.failures[method]; len(fails) != 0 {
+        self.failures[method] = fails[1:]
+        self.mu.Unlock()
+        reply(w, nil, &fails[0])
+        return
+    }
+    self.mu.Unlock()
+
+    switch method {
+    case "getMe":
+        reply(w, self.Me, nil)
+    case "getUpdates":
+        self.get_updates(w, r, body)
+    case "sendMessage":
+        self.send_message(w, body)
+    case "editMessageText":
+        self.edit_message_text(w, body)
+    case "deleteMessage":
+        self.delete_message(w, body)
+    case "sendDocument":
+        self.send_document(w, body)
+    case "sendPhoto":
+        self.send_photo(w, body)
+    default:
+        reply(w, nil, &tg_api.Error{ ErrorCode: 404, Description: "Not Found" })
+    }
+} // <-- Server::serve(w, r)
+
+func (self *Server) get_updates(w http.ResponseWriter, r *http.Request, body []byte) {
+    var params tg_api.GetUpdates
+    if err := json.Unmarshal(body, &params); err != nil {
+        reply(w, nil, bad_request(err.Error()))
+        return
+    }
+
+    deadline := time.After(time.Duration(params.Timeout) * time.Second)
+    for {
+        self.mu.Lock()
+        // Updates below the offset are confirmed and forgotten
+        kept := self.updates[:0]
+        for _, update := range self.updates {
+            if update.UpdateId >= params.Offset {
+                kept = append(kept, update)
+            }
+        }
+        self.updates = kept
+
+        if len(self.updates) != 0 || params.Timeout == 0 {
+            ret := append([]tg_api.Update{}, self.updates...)
+            self.mu.Unlock()
+            reply(w, ret, nil)
+            return
+        }
+        notify := self.notify
+        self.mu.Unlock()
+
+        select {
+        case <-notify:
+        case <-deadline:
+            reply(w, []tg_api.Update{}, nil)
+            return
+        case <-r.Context().Done():
+            return
+        }
+    }
+} // <-- Server::get_updates(w, r, body)
+
+func (self *Server) send_message(w http.ResponseWriter, body []byte) {
+    var params tg_api.SendMessage
+    if err := json.Unmarshal(body, &params); err != nil {
+        reply(w, nil, bad_request(err.Error()))
+        return
+    }
+    if len(params.Text) == 0 {
+        reply(w, nil, bad_request("message text is empty"))
+        return
+    }
+
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    msg := self.store(tg_api.Message{
+        From:     self.bot_user(),
+        Text:     params.Text,
+        Entities: params.Entities,
+        Chat:     tg_api.Chat{ Id: params.ChatId },
+    })
+    self.push_sent(msg)
+    reply(w, msg, nil)
+} // <-- Server::send_message(w, body)
+
+func (self *Server) send_document(w http.ResponseWriter, body []byte) {
+    var params struct {
+        ChatId   string `json:"chat_id"`
+        Caption  string `json:"caption"`
+        Document *File  `json:"document"`
+    }
+    if err := json.Unmarshal(body, &params); err != nil {
+        reply(w, nil, bad_request(err.Error()))
+        return
+    }
+    chat_id, err := strconv.Atoi(params.ChatId)
+    if err != nil {
+        reply(w, nil, bad_request("chat not found"))
+        return
+    }
+    if params.Document == nil {
+        reply(w, nil, bad_request("there is no document in the request"))
+        return
+    }
+
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    msg := self.store(tg_api.Message{
+        From:    self.bot_user(),
+        Chat:    tg_api.Chat{ Id: chat_id },
+        Caption: params.Caption,
+        Document: &tg_api.Document{
+            FileId:       fmt.Sprintf("document%d", self.next_msg_id),
+            FileUniqueId: fmt.Sprintf("document%d", self.next_msg_id),
+            FileName:     params.Document.Name,
+            FileSize:     len(params.Document.Data),
+        },
+    })
+    self.files[msg.Document.FileId] = params.Document.Data
+    self.push_sent(msg)
+    reply(w, msg, nil)
+} // <-- Server::send_document(w, body)
+
+func (self *Server) send_photo(w http.ResponseWriter, body []byte) {
+    var params struct {
+        ChatId  string `json:"chat_id"`
+        Caption string `json:"caption"`
+        Photo   *File  `json:"photo"`
+    }
+    if err := json.Unmarshal(body, &params); err != nil {
+        reply(w, nil, bad_request(err.Error()))
+        return
+    }
+    chat_id, err := strconv.Atoi(params.ChatId)
+    if err != nil {
+        reply(w, nil, bad_request("chat not found"))
+        return
+    }
+    if params.Photo == nil {
+        reply(w, nil, bad_request("there is no photo in the request"))
+        return
+    }
+
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    file_id := fmt.Sprintf("photo%d", self.next_msg_id)
+    msg := self.store(tg_api.Message{
+        From:    self.bot_user(),
+        Chat:    tg_api.Chat{ Id: chat_id },
+        Caption: params.Caption,
+        Photo: []tg_api.PhotoSize{{
+            FileId:       file_id,
+            FileUniqueId: file_id,
+            FileSize:     len(params.Photo.Data),
+        }},
+    })
+    self.files[file_id] = params.Photo.Data
+    self.push_sent(msg)
+    reply(w, msg, nil)
+} // <-- Server::send_photo(w, body)
+
+// Contents of a file the bot has uploaded
+func (self *Server) FileData(file_id string) ([]byte, bool) {
+    self.mu.Lock()
+    defer self.mu.Unlock()
+    data, found := self.files[file_id]
+    return data, found
+} // <-- Server::FileData(file_id)
+
+func (self *Server) edit_message_text(w http.ResponseWriter, body []byte) {
+    var params tg_api.EditMessageText
+    if err := json.Unmarshal(body, &params); err != nil {
+        reply(w, nil, bad_request(err.Error()))
+        return
+    }
+
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    msg, found := self.messages[params.MessageId]
+    if !found || msg.Chat.Id != params.ChatId {
+        reply(w, nil, bad_request("message to edit not found"))
+        return
+    }
+    if msg.From.Username != self.Me.Username {
+        reply(w, nil, bad_request("message can't be edited"))
+        return
+    }
+    if msg.Text == params.Text {
+        reply(w, nil, bad_request("message is not modified"))
+        return
+    }
+
+    msg.Text = params.Text
+    reply(w, *msg, nil)
+} // <-- Server::edit_message_text(w, body)
+
+func (self *Server) delete_message(w http.ResponseWriter, body []byte) {
+    var params tg_api.DeleteMessage
+    if err := json.Unmarshal(body, &params); err != nil {
+        reply(w, nil, bad_request(err.Error()))
+        return
+    }
+
+    self.mu.Lock()
+    defer self.mu.Unlock()
+
+    msg, found := self.messages[params.MessageId]
+    if !found || msg.Chat.Id != params.ChatId {
+        reply(w, nil, bad_request("message to delete not found"))
+        return
+    }
+
+    delete(self.messages, params.MessageId)
+    reply(w, true, nil)
+} // <-- Server::delete_message(w, body)
@@ -0,0 +1,142 @@
+package tgtest
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gregthemadmonk/mctg-server-bot/tg_api"
+)
+
+const token = "123:test"
+
+// Real client pointed at the fake server
+func make_client(t *testing.T, srv *Server, token string) *tg_api.Client {
+    client, err := tg_api.MakeClient(token, tg_api.ClientOptions{ BaseUrl: srv.Url() })
+    if err != nil {
+        t.Fatalf("MakeClient: %v", err)
+    }
+    return client
+} // <-- make_client(t, srv, token)
+
+func TestClient(t *testing.T) {
+    srv := MakeServer(token)
+    defer srv.Close()
+    client := make_client(t, srv, token)
+    ctx := context.Background()
+
+    me, err := client.GetMe(ctx)
+    if err != nil {
+        t.Fatalf("GetMe: %v", err)
+    }
+    if me.Username != srv.Me.Username {
+        t.Errorf("GetMe username = %q, want %q", me.Username, srv.Me.Username)
+    }
+
+    // A user writes, the client receives it with long polling
+    from := tg_api.User{ Id: 42, FirstName: "Alice" }
+    injected := srv.InjectMessage(-100, from, "hello")
+    updates, err := client.GetUpdates(ctx, tg_api.GetUpdates{ Timeout: 1 })
+    if err != nil {
+        t.Fatalf("GetUpdates: %v", err)
+    }
+    if len(updates) != 1 || updates[0].Message == nil ||
+        updates[0].Message.MessageId != injected.MessageId ||
+        updates[0].Message.Text != "hello" {
+        t.Fatalf("GetUpdates = %+v, want the injected message", updates)
+    }
+    // Confirmed updates are not returned again
+    updates, err = client.GetUpdates(
+        ctx, tg_api.GetUpdates{ Offset: updates[0].UpdateId + 1 },
+    )
+    if err != nil || len(updates) != 0 {
+        t.Fatalf("GetUpdates after confirming = %+v, %v, want none", updates, err)
+    }
+
+    // The bot answers and edits the answer
+    msg, err := client.SendMessage(
+        ctx, tg_api.SendMessage{ ChatId: -100, Text: "hi" },
+    )
+    if err != nil {
+        t.Fatalf("SendMessage: %v", err)
+    }
+    _, err = client.EditMessageText(ctx, tg_api.EditMessageText{
+        ChatId:    -100,
+        MessageId: msg.MessageId,
+        Text:      "hi, Alice",
+    })
+    if err != nil {
+        t.Fatalf("EditMessageText: %v", err)
+    }
+    sent, err := srv.WaitSent(1, time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(sent) != 1 || sent[0].Text != "hi" || sent[0].Chat.Id != -100 {
+        t.Errorf("Sent = %+v, want one message \"hi\" to -100", sent)
+    }
+    if stored, found := srv.Message(msg.MessageId); !found || stored.Text != "hi, Alice" {
+        t.Errorf("edited message = %+v, want \"hi, Alice\"", stored)
+    }
+
+    var methods []string
+    for _, call := range srv.Calls() {
+        methods = append(methods, call.Method)
+    }
+    want := []string{
+        "getMe", "getUpdates", "getUpdates", "sendMessage", "editMessageText",
+    }
+    if len(methods) != len(want) {
+        t.Fatalf("calls = %v, want %v", methods, want)
+    }
+    for i := range want {
+        if methods[i] != want[i] {
+            t.Fatalf("calls = %v, want %v", methods, want)
+        }
+    }
+} // <-- TestClient
+
+func TestClientErrors(t *testing.T) {
+    srv := MakeServer(token)
+    defer srv.Close()
+    ctx := context.Background()
+
+    var api_err *tg_api.Error
+
+    // Wrong token
+    _, err := make_client(t, srv, "456:wrong").GetMe(ctx)
+    if !errors.As(err, &api_err) || api_err.ErrorCode != 401 {
+        t.Errorf("GetMe with a wrong token = %v, want error code 401", err)
+    }
+
+    // Injected failure, only for the next call
+    client := make_client(t, srv, token)
+    srv.Fail("sendMessage", tg_api.Error{
+        ErrorCode:   400,
+        Description: "Bad Request: can't parse entities",
+    })
+    params := tg_api.SendMessage{ ChatId: 1, Text: "*", ParseMode: tg_api.PM_MARKDOWN }
+    _, err = client.SendMessage(ctx, params)
+    if !errors.As(err, &api_err) || api_err.ErrorCode != 400 ||
+        api_err.Method != "sendMessage" {
+        t.Errorf("SendMessage = %v, want the injected error", err)
+    }
+    if _, err = client.SendMessage(ctx, params); err != nil {
+        t.Errorf("SendMessage after the failure: %v", err)
+    }
+
+    // Unreachable server
+    srv.SetOffline(true)
+    _, err = client.GetMe(ctx)
+    if err == nil || errors.As(err, &api_err) {
+        t.Errorf("GetMe while offline = %v, want a connection error", err)
+    } else if strings.Contains(err.Error(), token) {
+        t.Errorf("connection error %q shows the token", err)
+    }
+    srv.SetOffline(false)
+    if _, err = client.GetMe(ctx); err != nil {
+        t.Errorf("GetMe back online: %v", err)
+    }
+} // <-- TestClientErrors
@@ -0,0 +1,37 @@
+// throttle.go
+// Suppress repeated messages
+package main
+
+import (
+    "time"
+)
+
+// Minimum time between two identical error messages
+const ERROR_THROTTLE = time.Minute
+
+// Lets the same key through at most once per period
+type throttle struct {
+    period time.Duration
+    last   map[string]time.Time
+} // <-- struct throttle
+
+func make_throttle(period time.Duration) throttle {
+    return throttle{ period: period, last: make(map[string]time.Time) }
+} // <-- make_throttle(period)
+
+// Check if the key may pass now, and remember that it did
+func (self *throttle) Allow(key string) bool {
+    now := time.Now()
+    if last, found := self.last[key]; found && now.Sub(last) < self.period {
+        return false
+    }
+
+    // Forget the stale keys so the map does not grow forever
+    for k, t := range self.last {
+        if now.Sub(t) >= self.period {
+            delete(self.last, k)
+        }
+    }
+    self.last[key] = now
+    return true
+} // <-- throttle::Allow(key)